@@ -0,0 +1,151 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultDeviceCacheTTL is how long devices obtained by GetDevices are cached, unless configured with WithDeviceCacheTTL.
+const DefaultDeviceCacheTTL = 5 * time.Minute
+
+// CachedDevices returns the devices cached by the client, fetching them with
+// GetDevicesContext if none are cached or they are older than the cache TTL.
+func (c *Client) CachedDevices(ctx context.Context) ([]Device, error) {
+	c.mu.RLock()
+	fresh := c.devicesFetchedAt.Add(c.deviceCacheTTL).After(time.Now())
+	devices := copyDevices(c.deviceCache)
+	c.mu.RUnlock()
+
+	if fresh {
+		return devices, nil
+	}
+	return c.GetDevicesContext(ctx)
+}
+
+// InvalidateDevices drops the cached devices, so that the next lookup fetches them again.
+func (c *Client) InvalidateDevices() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deviceCache = nil
+	c.devicesFetchedAt = time.Time{}
+	c.Devices = make([]string, 0)
+}
+
+// DeviceIDs returns the IDs of the devices cached by the client. It does not make any request.
+func (c *Client) DeviceIDs() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ids := make([]string, len(c.Devices))
+	copy(ids, c.Devices)
+	return ids
+}
+
+// DeviceByID returns the device with the given ID. The error wraps ErrNotFound if there is no such device.
+func (c *Client) DeviceByID(ctx context.Context, id string) (Device, error) {
+	devices, err := c.CachedDevices(ctx)
+	if err != nil {
+		return Device{}, err
+	}
+
+	for _, device := range devices {
+		if device.ID == id {
+			return device, nil
+		}
+	}
+	return Device{}, fmt.Errorf("[DeviceByID] no device with ID %q: %w", id, ErrNotFound)
+}
+
+// DeviceByTitle returns the device with the given title, e.g. "Ops Pixel". An
+// exact match is preferred over a case-insensitive one. The error wraps
+// ErrNotFound if there is no such device, and is returned as well if the title is ambiguous.
+func (c *Client) DeviceByTitle(ctx context.Context, title string) (Device, error) {
+	devices, err := c.CachedDevices(ctx)
+	if err != nil {
+		return Device{}, err
+	}
+
+	for _, equal := range []func(a, b string) bool{func(a, b string) bool { return a == b }, strings.EqualFold} {
+		var matches []Device
+		for _, device := range devices {
+			if equal(device.Title, title) {
+				matches = append(matches, device)
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			continue
+		case 1:
+			return matches[0], nil
+		default:
+			return Device{}, fmt.Errorf("[DeviceByTitle] %d devices are titled %q, use their IDs instead", len(matches), title)
+		}
+	}
+
+	return Device{}, fmt.Errorf("[DeviceByTitle] no device titled %q: %w", title, ErrNotFound)
+}
+
+// cacheDevices replaces the cached devices.
+func (c *Client) cacheDevices(devices []Device) {
+	ids := make([]string, 0, len(devices))
+	for _, device := range devices {
+		ids = append(ids, device.ID)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deviceCache = copyDevices(devices)
+	c.devicesFetchedAt = time.Now()
+	c.Devices = ids
+}
+
+// uniqueDevices returns devices without duplicate IDs, keeping the first occurrence.
+func uniqueDevices(devices []Device) []Device {
+	seen := make(map[string]bool, len(devices))
+	unique := make([]Device, 0, len(devices))
+
+	for _, device := range devices {
+		if seen[device.ID] {
+			continue
+		}
+		seen[device.ID] = true
+		unique = append(unique, device)
+	}
+	return unique
+}
+
+func copyDevices(devices []Device) []Device {
+	if devices == nil {
+		return nil
+	}
+
+	devicesCopy := make([]Device, len(devices))
+	copy(devicesCopy, devices)
+	return devicesCopy
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceCache(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var calls int32
+	handler.HandleFunc("/devices", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		fmt.Fprint(w, `[
+			{"id": "abcd", "title": "Ops Pixel", "model": "Pixel 6", "image": ""},
+			{"id": "efgh", "title": "Tablet", "model": "iPad", "image": ""},
+			{"id": "abcd", "title": "Ops Pixel", "model": "Pixel 6", "image": ""}
+		]`)
+	})
+
+	pn, err := New("dev.myapp.pn", "aabbccdd112233", WithBaseURL(server.URL), WithAppToken("ZZXX11ff", time.Time{}))
+	if !assert.NoError(err) {
+		return
+	}
+
+	devices, err := pn.GetDevices()
+	assert.NoError(err)
+	assert.Len(devices, 2, "[TestDeviceCache] Expected duplicate devices to be dropped")
+
+	_, err = pn.GetDevices()
+	assert.NoError(err)
+	assert.Equal([]string{"abcd", "efgh"}, pn.DeviceIDs(), "[TestDeviceCache] Expected devices to be replaced instead of appended")
+
+	device, err := pn.DeviceByTitle(context.Background(), "ops pixel")
+	assert.NoError(err)
+	assert.Equal("abcd", device.ID)
+
+	device, err = pn.DeviceByID(context.Background(), "efgh")
+	assert.NoError(err)
+	assert.Equal("Tablet", device.Title)
+
+	_, err = pn.DeviceByTitle(context.Background(), "Watch")
+	assert.ErrorIs(err, ErrNotFound)
+
+	assert.Equal(int32(2), atomic.LoadInt32(&calls), "[TestDeviceCache] Expected lookups to use the cached devices")
+
+	pn.InvalidateDevices()
+	assert.Empty(pn.DeviceIDs())
+
+	_, err = pn.CachedDevices(context.Background())
+	assert.NoError(err)
+	assert.Equal(int32(3), atomic.LoadInt32(&calls), "[TestDeviceCache] Expected devices to be fetched again after invalidating the cache")
+}
@@ -0,0 +1,118 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors describing the class of a failed API call. An *APIError
+// matches the sentinel for its status code, so callers can branch with
+// errors.Is(err, pushnotifier.ErrUnauthorized) without inspecting status codes.
+var (
+	// ErrBadRequest is returned when the API rejects a malformed request (400).
+	ErrBadRequest = errors.New("pushnotifier: bad request")
+	// ErrUnauthorized is returned when the package name, API token or App Token are invalid or expired (401).
+	ErrUnauthorized = errors.New("pushnotifier: unauthorized")
+	// ErrForbidden is returned when the credentials are valid but not allowed to perform the request, e.g. a wrong password on login (403).
+	ErrForbidden = errors.New("pushnotifier: forbidden")
+	// ErrNotFound is returned when the user or one of the given devices does not exist (404).
+	ErrNotFound = errors.New("pushnotifier: not found")
+	// ErrPayloadTooLarge is returned when the request body, usually an image, exceeds the API limit (413).
+	ErrPayloadTooLarge = errors.New("pushnotifier: payload too large")
+	// ErrRateLimited is returned when too many requests have been sent (429).
+	ErrRateLimited = errors.New("pushnotifier: rate limited")
+	// ErrServer is returned when the API fails to handle an otherwise valid request (5xx).
+	ErrServer = errors.New("pushnotifier: server error")
+)
+
+// APIError is returned for every response from the API with a non-200 status code.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response, e.g. 404.
+	StatusCode int
+	// Status is the HTTP status line of the response, e.g. "404 Not Found".
+	Status string
+	// Method is the HTTP method of the failed request.
+	Method string
+	// Endpoint is the API resource that was requested, relative to the client's BaseURL, e.g. "notifications/text".
+	Endpoint string
+	// Errors holds the entries of the `error` array in the response body, if the server sent one.
+	Errors []string
+	// Body is the raw response body.
+	Body string
+}
+
+func (e *APIError) Error() string {
+	msg := strings.TrimSpace(e.Body)
+	if len(e.Errors) > 0 {
+		msg = strings.Join(e.Errors, ", ")
+	}
+
+	if msg == "" {
+		return fmt.Sprintf("pushnotifier: %v %v: %v", e.Method, e.Endpoint, e.Status)
+	}
+	return fmt.Sprintf("pushnotifier: %v %v: %v - %v", e.Method, e.Endpoint, e.Status, msg)
+}
+
+// Is reports whether target is the sentinel error matching e.StatusCode.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrPayloadTooLarge:
+		return e.StatusCode == http.StatusRequestEntityTooLarge
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500 && e.StatusCode <= 599
+	}
+	return false
+}
+
+// newAPIError builds an *APIError from a non-200 response. The body is
+// decoded as serverRespSuccess when possible to extract the server's error list.
+func newAPIError(method, endpoint string, resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Method:     method,
+		Endpoint:   endpoint,
+		Body:       string(body),
+	}
+
+	var sResp serverRespSuccess
+	if err := json.Unmarshal(body, &sResp); err == nil {
+		apiErr.Errors = sResp.Error
+	}
+
+	return apiErr
+}
@@ -0,0 +1,87 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		statusCode int
+		want       error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusRequestEntityTooLarge, ErrPayloadTooLarge},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusBadGateway, ErrServer},
+	}
+
+	sentinels := []error{ErrBadRequest, ErrUnauthorized, ErrForbidden, ErrNotFound, ErrPayloadTooLarge, ErrRateLimited, ErrServer}
+
+	for _, tt := range tests {
+		err := fmt.Errorf("wrapped: %w", &APIError{StatusCode: tt.statusCode})
+
+		for _, sentinel := range sentinels {
+			assert.Equal(sentinel == tt.want, errors.Is(err, sentinel), "[TestAPIErrorIs] status %v matched against %v", tt.statusCode, sentinel)
+		}
+	}
+}
+
+func TestAPIErrorFromResponse(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	handler.HandleFunc("/notifications/text", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"success": [], "error": ["abcd"]}`)
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+
+	_, err := pn.SendText("hello world", []string{"abcd"}, false)
+
+	var apiErr *APIError
+	if assert.ErrorAs(err, &apiErr, "[TestAPIErrorFromResponse] Expected an *APIError") {
+		assert.Equal(http.StatusNotFound, apiErr.StatusCode)
+		assert.Equal("PUT", apiErr.Method)
+		assert.Equal("notifications/text", apiErr.Endpoint)
+		assert.Equal([]string{"abcd"}, apiErr.Errors)
+	}
+	assert.ErrorIs(err, ErrNotFound, "[TestAPIErrorFromResponse] Expected error to match ErrNotFound")
+}
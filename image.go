@@ -0,0 +1,328 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// MaxImageSize is the largest image, in bytes, pushnotifier.de accepts.
+const MaxImageSize = 5_000_000
+
+type (
+	// Image is the image of a KindImage notification. It is read from exactly one
+	// of Path, Data and Reader, and base64 encoded while it is sent.
+	Image struct {
+		// Path is the path to the image file.
+		Path string
+		// Data holds the image in memory.
+		Data []byte
+		// Reader is read for the image. As it can only be read once, a request
+		// sending it is not retried.
+		Reader io.Reader
+		// Name is the file name the image is sent with. It defaults to the base
+		// name of Path, and must be set for Data and Reader. If it has no
+		// extension, the one of the image's content type is added.
+		Name string
+		// Fit, if set, re-encodes and downscales a PNG, JPEG or GIF image larger
+		// than MaxImageSize until it fits, see FitImage, instead of failing.
+		Fit bool
+	}
+
+	// streamBody is a request body that is written while it is sent. The request
+	// sets its Content-Length to size if it is known, and uses reopen, if set, to
+	// send it again.
+	streamBody struct {
+		io.ReadCloser
+		size   int64
+		reopen func() (io.ReadCloser, error)
+
+		mu  sync.Mutex
+		err error
+	}
+)
+
+// Err returns the error that stopped writing the body, if any.
+func (b *streamBody) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// validate checks that img has exactly one source, and a name if it cannot be derived from the source.
+func (img *Image) validate() error {
+	sources := 0
+	for _, set := range []bool{img.Path != "", img.Data != nil, img.Reader != nil} {
+		if set {
+			sources++
+		}
+	}
+
+	switch {
+	case sources == 0:
+		return fmt.Errorf("image has no path, data or reader: %w", ErrInvalidNotification)
+	case sources > 1:
+		return fmt.Errorf("image has more than one of path, data and reader: %w", ErrInvalidNotification)
+	case img.Path == "" && img.Name == "":
+		return fmt.Errorf("image read from data or a reader has no name: %w", ErrInvalidNotification)
+	}
+	return nil
+}
+
+// open returns a reader for the image along with its name, and its size or -1 if it is unknown.
+func (img *Image) open() (r io.ReadCloser, name string, size int64, err error) {
+	switch {
+	case img.Data != nil:
+		return io.NopCloser(bytes.NewReader(img.Data)), img.Name, int64(len(img.Data)), nil
+	case img.Reader != nil:
+		return io.NopCloser(img.Reader), img.Name, -1, nil
+	}
+
+	file, err := os.Open(img.Path)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	osStat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, "", 0, err
+	}
+
+	// check if given path is to a file
+	if osStat.IsDir() {
+		file.Close()
+		return nil, "", 0, fmt.Errorf("given image file path is a directory and not an actual file: %w", ErrInvalidNotification)
+	}
+
+	name = img.Name
+	if name == "" {
+		name = osStat.Name()
+	}
+	return file, name, osStat.Size(), nil
+}
+
+// sniffImage detects the content type of the image read from src, and fails if
+// it is not an image. The returned reader reads the whole image from src.
+func sniffImage(src io.ReadCloser) (io.ReadCloser, string, error) {
+	buffered := bufio.NewReaderSize(src, 512)
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+
+	contentType := http.DetectContentType(head)
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", fmt.Errorf("image content is %v, not an image: %w", contentType, ErrInvalidNotification)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{buffered, src}, contentType, nil
+}
+
+// imageExtensions maps the content types of images to the extension of their file name.
+var imageExtensions = map[string]string{
+	"image/bmp":                ".bmp",
+	"image/gif":                ".gif",
+	"image/jpeg":               ".jpg",
+	"image/png":                ".png",
+	"image/webp":               ".webp",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+}
+
+// fit reads the image and returns it fitted to MaxImageSize, see FitImage.
+func (img *Image) fit() (*Image, *ImageFit, error) {
+	src, name, _, err := img.open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxFitInputSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) > maxFitInputSize {
+		return nil, nil, fmt.Errorf("image is too large to be fitted: %w", ErrPayloadTooLarge)
+	}
+
+	if contentType := http.DetectContentType(data); !strings.HasPrefix(contentType, "image/") {
+		return nil, nil, fmt.Errorf("image content is %v, not an image: %w", contentType, ErrInvalidNotification)
+	}
+
+	data, fit, err := FitImage(data, MaxImageSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Image{Data: data, Name: name}, fit, nil
+}
+
+// body returns the request body sending img to devices, and with img.Fit how
+// it was fitted to the size limit. The image is read and base64 encoded while
+// the body is read, so it is never held in memory twice.
+func (img *Image) body(devices []string, silent bool) (*streamBody, *ImageFit, error) {
+	var fit *ImageFit
+	if img.Fit {
+		fitted, f, err := img.fit()
+		if err != nil {
+			return nil, nil, err
+		}
+		img, fit = fitted, f
+	}
+
+	src, name, size, err := img.open()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// check if file size is greater than 5_000_000 bytes or 5 Megabytes (MB)
+	if size > MaxImageSize {
+		src.Close()
+		return nil, nil, fmt.Errorf("given file size is greater than 5 MegaBytes (MB): %w", ErrPayloadTooLarge)
+	}
+
+	// Reject anything that is not an image before uploading it.
+	sniffed, contentType, err := sniffImage(src)
+	if err != nil {
+		src.Close()
+		return nil, nil, err
+	}
+	src = sniffed
+
+	if filepath.Ext(name) == "" {
+		name += imageExtensions[contentType]
+	}
+
+	// The content is written last, between prefix and suffix, so that it can be streamed.
+	head, err := json.Marshal(struct {
+		Devices  []string `json:"devices"`
+		Filename string   `json:"filename"`
+		Silent   bool     `json:"silent"`
+	}{devices, name, silent})
+	if err != nil {
+		src.Close()
+		return nil, nil, err
+	}
+	prefix := append(head[:len(head)-1], `,"content":"`...)
+	suffix := []byte(`"}`)
+
+	body := &streamBody{size: -1}
+	if size >= 0 {
+		body.size = int64(len(prefix) + base64.StdEncoding.EncodedLen(int(size)) + len(suffix))
+	}
+
+	start := func(src io.ReadCloser) io.ReadCloser {
+		pr, pw := io.Pipe()
+		go func() {
+			err := encodeImage(pw, prefix, suffix, src)
+			src.Close()
+
+			// The pipe is closed early when the request ends before the whole body was
+			// sent, e.g. with an error response, in which case that is the error to report.
+			if err != nil && !errors.Is(err, io.ErrClosedPipe) {
+				body.mu.Lock()
+				body.err = err
+				body.mu.Unlock()
+			}
+
+			pw.CloseWithError(err)
+		}()
+		return pr
+	}
+
+	body.ReadCloser = start(src)
+	if img.Reader == nil {
+		body.reopen = func() (io.ReadCloser, error) {
+			src, _, _, err := img.open()
+			if err != nil {
+				return nil, err
+			}
+
+			sniffed, _, err := sniffImage(src)
+			if err != nil {
+				src.Close()
+				return nil, err
+			}
+			return start(sniffed), nil
+		}
+	}
+	return body, fit, nil
+}
+
+// encodeImage writes prefix, the base64 encoded image read from src and suffix
+// to w. It fails with ErrPayloadTooLarge once more than MaxImageSize bytes are read.
+func encodeImage(w io.Writer, prefix, suffix []byte, src io.Reader) error {
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+
+	encoder := base64.NewEncoder(base64.StdEncoding, w)
+	n, err := io.Copy(encoder, io.LimitReader(src, MaxImageSize+1))
+	if err != nil {
+		return err
+	}
+	if n > MaxImageSize {
+		return fmt.Errorf("image is greater than 5 MegaBytes (MB): %w", ErrPayloadTooLarge)
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	_, err = w.Write(suffix)
+	return err
+}
+
+// SendImageReader sends a notification to all registered clients with an image
+// read from r, named filename. As r can only be read once, the request is not retried.
+func (c *Client) SendImageReader(r io.Reader, filename string, devices []string, silent bool) (*SendResult, error) {
+	return c.SendImageReaderContext(context.Background(), r, filename, devices, silent)
+}
+
+// SendImageReaderContext is like SendImageReader but aborts the request when ctx is done.
+func (c *Client) SendImageReaderContext(ctx context.Context, r io.Reader, filename string, devices []string, silent bool) (*SendResult, error) {
+	return c.Send(ctx, Notification{Kind: KindImage, Image: &Image{Reader: r, Name: filename}, Devices: devices, Silent: silent})
+}
+
+// SendImageBytes sends a notification to all registered clients with an image held in data, named filename.
+func (c *Client) SendImageBytes(data []byte, filename string, devices []string, silent bool) (*SendResult, error) {
+	return c.SendImageBytesContext(context.Background(), data, filename, devices, silent)
+}
+
+// SendImageBytesContext is like SendImageBytes but aborts the request when ctx is done.
+func (c *Client) SendImageBytesContext(ctx context.Context, data []byte, filename string, devices []string, silent bool) (*SendResult, error) {
+	return c.Send(ctx, Notification{Kind: KindImage, Image: &Image{Data: data, Name: filename}, Devices: devices, Silent: silent})
+}
@@ -0,0 +1,122 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendImageBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	image := bytes.Repeat([]byte("\x89PNG\r\n\x1a\n"), 100)
+
+	var attempts int
+	handler.HandleFunc("/notifications/image", func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+
+		raw, _ := io.ReadAll(r.Body)
+		assert.Equal(int64(len(raw)), r.ContentLength, "[TestSendImageBytes] Expected Content-Length to be set to the size of the body")
+
+		var body struct {
+			Devices  []string `json:"devices"`
+			Content  string   `json:"content"`
+			Filename string   `json:"filename"`
+			Silent   bool     `json:"silent"`
+		}
+		assert.NoError(json.Unmarshal(raw, &body), "[TestSendImageBytes] Expected body to be JSON")
+		assert.Equal([]string{"abcd"}, body.Devices)
+		assert.Equal("chart.png", body.Filename)
+		assert.Equal(base64.StdEncoding.EncodeToString(image), body.Content, "[TestSendImageBytes] Expected image to be base64 encoded")
+
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"success": ["abcd"], "error": []}`)
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+	pn.RetryPolicy = &RetryPolicy{MaxAttempts: 2, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond, RetryableStatusCodes: []int{http.StatusServiceUnavailable}}
+
+	result, err := pn.SendImageBytes(image, "chart.png", []string{"abcd"}, false)
+	assert.NoError(err, "[TestSendImageBytes] Expected image to be sent")
+	assert.Equal([]string{"abcd"}, result.Delivered)
+	assert.Equal(2, attempts, "[TestSendImageBytes] Expected image in memory to be sent again when retried")
+}
+
+func TestSendImageReader(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var attempts int
+	handler.HandleFunc("/notifications/image", func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+	pn.RetryPolicy = &RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond, RetryableStatusCodes: []int{http.StatusServiceUnavailable}}
+
+	_, err := pn.SendImageReader(strings.NewReader("\x89PNG\r\n\x1a\n"), "chart.png", []string{"abcd"}, false)
+	assert.ErrorIs(err, ErrServer, "[TestSendImageReader] Expected the error response to be returned")
+	assert.Equal(1, attempts, "[TestSendImageReader] Expected image read from a reader to not be retried")
+
+	_, err = pn.SendImageReader(io.MultiReader(strings.NewReader("\x89PNG\r\n\x1a\n"), zeroReader{}), "chart.png", []string{"abcd"}, false)
+	assert.ErrorIs(err, ErrPayloadTooLarge, "[TestSendImageReader] Expected image larger than 5 MB to be rejected")
+
+	_, err = pn.Send(context.Background(), Notification{Image: &Image{Data: []byte("\x89PNG\r\n\x1a\n")}, Devices: []string{"abcd"}})
+	assert.ErrorIs(err, ErrInvalidNotification, "[TestSendImageReader] Expected image without a name to be rejected")
+}
+
+// zeroReader is an endless stream of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
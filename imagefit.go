@@ -0,0 +1,182 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+)
+
+const (
+	// maxFitInputSize is the largest image, in bytes, read to be fitted to MaxImageSize.
+	maxFitInputSize = 64 << 20
+	// maxFitPixels is the largest image, in pixels, decoded to be fitted to MaxImageSize.
+	maxFitPixels = 100_000_000
+)
+
+// ImageFit describes how an image was fitted to the size limit by FitImage.
+type ImageFit struct {
+	// Format is the format of the image, i.e. "png", "jpeg" or "gif".
+	Format string
+	// OriginalWidth and OriginalHeight are the dimensions of the image before it was fitted.
+	OriginalWidth, OriginalHeight int
+	// Width and Height are the dimensions of the fitted image.
+	Width, Height int
+	// OriginalSize and Size are the sizes, in bytes, of the image before and after it was fitted.
+	OriginalSize, Size int
+}
+
+// Resized reports whether the image was downscaled to fit.
+func (f *ImageFit) Resized() bool {
+	return f.Width != f.OriginalWidth || f.Height != f.OriginalHeight
+}
+
+// FitImage returns the PNG, JPEG or GIF image held in data re-encoded, and if
+// that is not enough downscaled, until it is at most limit bytes large. The
+// image keeps its format, but an animated GIF is reduced to its first frame.
+// An image that already fits is returned as it is.
+//
+// The error wraps ErrInvalidNotification if data is not a supported image, and
+// ErrPayloadTooLarge if it cannot be made to fit.
+func FitImage(data []byte, limit int) ([]byte, *ImageFit, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to decode image: %v: %w", err, ErrInvalidNotification)
+	}
+
+	fit := &ImageFit{
+		Format:         format,
+		OriginalWidth:  config.Width,
+		OriginalHeight: config.Height,
+		Width:          config.Width,
+		Height:         config.Height,
+		OriginalSize:   len(data),
+		Size:           len(data),
+	}
+	if len(data) <= limit {
+		return data, fit, nil
+	}
+
+	if config.Width*config.Height > maxFitPixels {
+		return nil, nil, fmt.Errorf("image of %vx%v pixels is too large to be fitted: %w", config.Width, config.Height, ErrPayloadTooLarge)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to decode image: %v: %w", err, ErrInvalidNotification)
+	}
+
+	img := src
+	for {
+		encoded, err := encodeImageAs(format, img)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		bounds := img.Bounds()
+		if len(encoded) <= limit {
+			fit.Width, fit.Height = bounds.Dx(), bounds.Dy()
+			fit.Size = len(encoded)
+			return encoded, fit, nil
+		}
+
+		// The encoded size shrinks about as much as the number of pixels, so scale
+		// both sides by the square root of how much too large the image still is,
+		// and a little more so that the loop ends quickly.
+		scale := math.Sqrt(float64(limit)/float64(len(encoded))) * 0.9
+		width := int(float64(bounds.Dx()) * scale)
+		height := int(float64(bounds.Dy()) * scale)
+		if width < 1 || height < 1 {
+			return nil, nil, fmt.Errorf("image cannot be downscaled to fit %v bytes: %w", limit, ErrPayloadTooLarge)
+		}
+
+		img = downscale(src, width, height)
+	}
+}
+
+// encodeImageAs encodes img in the given format, favouring size over quality.
+func encodeImageAs(format string, img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+
+	switch format {
+	case "png":
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		err = encoder.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80})
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		return nil, fmt.Errorf("%v images cannot be fitted, only png, jpeg and gif: %w", format, ErrPayloadTooLarge)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// downscale returns src scaled down to width x height pixels, each of which is
+// the average of the pixels of src it covers.
+func downscale(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/height
+		if y1 == y0 {
+			y1++
+		}
+
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/width
+			if x1 == x0 {
+				x1++
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					sr, sg, sb, sa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(sr), g+uint64(sg), b+uint64(sb), a+uint64(sa)
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(b / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,127 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// noiseImage returns an image of random pixels, which compresses badly.
+func noiseImage(width, height int) *image.RGBA {
+	rnd := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), 255})
+		}
+	}
+	return img
+}
+
+func TestFitImage(t *testing.T) {
+	assert := assert.New(t)
+
+	var pngData bytes.Buffer
+	assert.NoError(png.Encode(&pngData, noiseImage(400, 200)))
+
+	data, fit, err := FitImage(pngData.Bytes(), pngData.Len())
+	assert.NoError(err)
+	assert.Equal(pngData.Bytes(), data, "[TestFitImage] Expected image that fits to be returned as it is")
+	assert.False(fit.Resized(), "[TestFitImage] Expected image that fits to not be resized")
+
+	limit := pngData.Len() / 4
+	data, fit, err = FitImage(pngData.Bytes(), limit)
+	assert.NoError(err, "[TestFitImage] Expected PNG to be fitted")
+	assert.LessOrEqual(len(data), limit, "[TestFitImage] Expected fitted PNG to be within the limit")
+	assert.True(fit.Resized(), "[TestFitImage] Expected PNG to be downscaled")
+	assert.Equal("png", fit.Format)
+	assert.Equal(400, fit.OriginalWidth)
+	assert.Equal(200, fit.OriginalHeight)
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	assert.NoError(err, "[TestFitImage] Expected fitted PNG to be decodable")
+	assert.Equal("png", format, "[TestFitImage] Expected fitted image to keep its format")
+	assert.Equal(fit.Width, config.Width)
+	assert.Equal(fit.Height, config.Height)
+	assert.InDelta(2.0, float64(fit.Width)/float64(fit.Height), 0.1, "[TestFitImage] Expected fitted image to keep its aspect ratio")
+
+	var jpegData bytes.Buffer
+	assert.NoError(jpeg.Encode(&jpegData, noiseImage(300, 300), &jpeg.Options{Quality: 100}))
+
+	data, fit, err = FitImage(jpegData.Bytes(), jpegData.Len()/3)
+	assert.NoError(err, "[TestFitImage] Expected JPEG to be fitted")
+	assert.LessOrEqual(len(data), jpegData.Len()/3, "[TestFitImage] Expected fitted JPEG to be within the limit")
+	assert.Equal("jpeg", fit.Format)
+
+	_, _, err = FitImage([]byte("hello world"), 5)
+	assert.ErrorIs(err, ErrInvalidNotification, "[TestFitImage] Expected data that is not an image to be rejected")
+}
+
+func TestSendImageSniffing(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var filename string
+	handler.HandleFunc("/notifications/image", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Filename string `json:"filename"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		filename = body.Filename
+		fmt.Fprint(w, `{"success": ["abcd"], "error": []}`)
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+
+	_, err := pn.SendImageBytes([]byte("hello world"), "chart.png", []string{"abcd"}, false)
+	assert.ErrorIs(err, ErrInvalidNotification, "[TestSendImageSniffing] Expected data that is not an image to be rejected")
+	assert.Empty(filename, "[TestSendImageSniffing] Expected data that is not an image to not be uploaded")
+
+	var pngData bytes.Buffer
+	assert.NoError(png.Encode(&pngData, noiseImage(20, 10)))
+
+	result, err := pn.Send(context.Background(), Notification{Image: &Image{Data: pngData.Bytes(), Name: "chart", Fit: true}, Devices: []string{"abcd"}})
+	assert.NoError(err)
+	assert.Equal("chart.png", filename, "[TestSendImageSniffing] Expected the extension of the image type to be added to its name")
+	if assert.NotNil(result.Image, "[TestSendImageSniffing] Expected fitting to be reported") {
+		assert.False(result.Image.Resized(), "[TestSendImageSniffing] Expected image that fits to not be resized")
+		assert.Equal(20, result.Image.Width)
+	}
+}
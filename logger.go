@@ -0,0 +1,129 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger is used by Client to report what it is doing. Messages are
+// accompanied by alternating keys and values, e.g. "count", 3.
+//
+// The method set matches that of *slog.Logger, so one can be used directly.
+// Client never logs App Tokens, and only logs device IDs at debug level.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// Level is the minimum severity of messages written by a logger created with NewStdLogger.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("LEVEL(%d)", int(l))
+}
+
+type (
+	nopLogger struct{}
+
+	stdLogger struct {
+		logger *log.Logger
+		level  Level
+	}
+)
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// NewNopLogger returns a Logger that discards all messages. It is the default logger of a Client.
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+// NewStdLogger returns a Logger that writes messages of at least the given level to l
+// in the form `LEVEL message key=value ...`. If l is nil, log.Default() is used.
+func NewStdLogger(l *log.Logger, level Level) Logger {
+	if l == nil {
+		l = log.Default()
+	}
+	return &stdLogger{logger: l, level: level}
+}
+
+func (s *stdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	s.log(LevelDebug, msg, keysAndValues)
+}
+
+func (s *stdLogger) Info(msg string, keysAndValues ...interface{}) {
+	s.log(LevelInfo, msg, keysAndValues)
+}
+
+func (s *stdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	s.log(LevelWarn, msg, keysAndValues)
+}
+
+func (s *stdLogger) Error(msg string, keysAndValues ...interface{}) {
+	s.log(LevelError, msg, keysAndValues)
+}
+
+func (s *stdLogger) log(level Level, msg string, keysAndValues []interface{}) {
+	if level < s.level {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(level.String())
+	b.WriteString(" ")
+	b.WriteString(msg)
+
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 < len(keysAndValues) {
+			fmt.Fprintf(&b, " %v=%v", keysAndValues[i], keysAndValues[i+1])
+		} else {
+			fmt.Fprintf(&b, " %v", keysAndValues[i])
+		}
+	}
+
+	s.logger.Print(b.String())
+}
@@ -0,0 +1,74 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdLoggerLevel(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0), LevelWarn)
+
+	logger.Info("not written")
+	logger.Warn("written", "count", 2)
+
+	assert.Equal("WARN written count=2\n", buf.String(), "[TestStdLoggerLevel] Expected only messages at or above the level to be written")
+}
+
+func TestLoggerHidesSecrets(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	handler.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"username": "aUser", "app_token": "ZZXX11ff", "expires_at": 4102444800}`)
+	})
+	handler.HandleFunc("/devices", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": "abcd", "title": "Pixel", "model": "Pixel 6", "image": ""}]`)
+	})
+
+	var buf bytes.Buffer
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "")
+	pn.BaseURL, _ = url.Parse(server.URL)
+	pn.Logger = NewStdLogger(log.New(&buf, "", 0), LevelInfo)
+
+	assert.NoError(pn.Login("aUser", "aUserPassword"))
+	_, err := pn.GetDevices()
+	assert.NoError(err)
+
+	assert.NotEmpty(buf.String(), "[TestLoggerHidesSecrets] Expected info messages to be logged")
+	assert.NotContains(buf.String(), "ZZXX11ff", "[TestLoggerHidesSecrets] Expected App Token to not be logged")
+	assert.NotContains(buf.String(), "abcd", "[TestLoggerHidesSecrets] Expected device IDs to not be logged")
+}
@@ -0,0 +1,296 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"unicode/utf8"
+)
+
+// ErrInvalidNotification is wrapped by the error Send returns for a notification that cannot be sent as given.
+var ErrInvalidNotification = errors.New("pushnotifier: invalid notification")
+
+// Kind is the kind of a Notification, which selects the endpoint it is sent to.
+type Kind string
+
+const (
+	// KindText is a notification with a text.
+	KindText Kind = "text"
+	// KindURL is a notification with a URL.
+	KindURL Kind = "url"
+	// KindNotification is a notification with a text, which opens a URL when tapped.
+	KindNotification Kind = "notification"
+	// KindImage is a notification with an image.
+	KindImage Kind = "image"
+)
+
+type (
+	// Notification is a notification to send with Send.
+	//
+	// If Kind is empty it is inferred from the fields that are set: an Image is
+	// sent as KindImage, a Text and a URL as KindNotification, and a Text or a
+	// URL alone as KindText or KindURL. If Devices is empty, the notification is
+	// sent to all devices registered by the user. Overflow, if set, overrides the
+	// client's OverflowPolicy for a text longer than MaxTextLength.
+	Notification struct {
+		Kind     Kind
+		Text     string
+		URL      string
+		Image    *Image
+		Devices  []string
+		Silent   bool
+		Overflow OverflowPolicy
+	}
+)
+
+// kind returns the kind of n, inferring it if n.Kind is empty.
+func (n Notification) kind() Kind {
+	switch {
+	case n.Kind != "":
+		return n.Kind
+	case n.Image != nil:
+		return KindImage
+	case n.Text != "" && n.URL != "":
+		return KindNotification
+	case n.URL != "":
+		return KindURL
+	default:
+		return KindText
+	}
+}
+
+// overflow returns the overflow policy n is sent with by c.
+func (n Notification) overflow(c *Client) OverflowPolicy {
+	if n.Overflow != "" {
+		return n.Overflow
+	}
+	return c.overflow
+}
+
+// validate checks that n has the fields required by, and only those allowed for, its kind.
+func (n Notification) validate() error {
+	kind := n.kind()
+
+	var missing, unexpected string
+	switch kind {
+	case KindText:
+		switch {
+		case n.Text == "":
+			missing = "text"
+		case n.URL != "":
+			unexpected = "URL"
+		case n.Image != nil:
+			unexpected = "image"
+		}
+	case KindURL:
+		switch {
+		case n.URL == "":
+			missing = "URL"
+		case n.Text != "":
+			unexpected = "text"
+		case n.Image != nil:
+			unexpected = "image"
+		}
+	case KindNotification:
+		switch {
+		case n.Text == "":
+			missing = "text"
+		case n.URL == "":
+			missing = "URL"
+		case n.Image != nil:
+			unexpected = "image"
+		}
+	case KindImage:
+		switch {
+		case n.Image == nil:
+			missing = "image"
+		case n.Text != "":
+			unexpected = "text"
+		case n.URL != "":
+			unexpected = "URL"
+		}
+	default:
+		return fmt.Errorf("unknown kind %q: %w", kind, ErrInvalidNotification)
+	}
+
+	if missing != "" {
+		return fmt.Errorf("%v notification has no %v: %w", kind, missing, ErrInvalidNotification)
+	}
+	if unexpected != "" {
+		return fmt.Errorf("%v notification cannot have a %v: %w", kind, unexpected, ErrInvalidNotification)
+	}
+
+	switch n.Overflow {
+	case "", OverflowError, OverflowTruncate, OverflowSplit:
+	default:
+		return fmt.Errorf("unknown overflow policy %q: %w", n.Overflow, ErrInvalidNotification)
+	}
+
+	if n.URL != "" {
+		if _, err := url.Parse(n.URL); err != nil {
+			return fmt.Errorf("%v: %w", err, ErrInvalidNotification)
+		}
+	}
+	if n.Image != nil {
+		return n.Image.validate()
+	}
+	return nil
+}
+
+// payload returns the request body a text, URL or notification n is sent with
+// to devices. Images are streamed instead, see Image.body.
+func (n Notification) payload(devices []string) interface{} {
+	switch n.kind() {
+	case KindText:
+		return struct {
+			Devices []string `json:"devices"`
+			Content string   `json:"content"`
+			Silent  bool     `json:"silent"`
+		}{devices, n.Text, n.Silent}
+	case KindURL:
+		parsedURL, _ := url.Parse(n.URL)
+		return struct {
+			Devices []string `json:"devices"`
+			URL     string   `json:"url"`
+			Silent  bool     `json:"silent"`
+		}{devices, parsedURL.String(), n.Silent}
+	default:
+		parsedURL, _ := url.Parse(n.URL)
+		return struct {
+			Devices []string `json:"devices"`
+			Content string   `json:"content"`
+			URL     string   `json:"url"`
+			Silent  bool     `json:"silent"`
+		}{devices, n.Text, parsedURL.String(), n.Silent}
+	}
+}
+
+// Send validates n and sends it to the endpoint for its kind. The returned
+// SendResult lists the devices the notification was and was not delivered to.
+// If it was not delivered to any device, the error wraps ErrDeliveryFailed.
+//
+// A text longer than MaxTextLength is handled according to n.Overflow or else
+// the client's OverflowPolicy, while a URL longer than MaxURLLength is always rejected.
+func (c *Client) Send(ctx context.Context, n Notification) (*SendResult, error) {
+	if err := n.validate(); err != nil {
+		return nil, fmt.Errorf("[Send] %w", err)
+	}
+
+	if length := utf8.RuneCountInString(n.URL); length > MaxURLLength {
+		return nil, fmt.Errorf("[Send] URL of %v characters is longer than %v: %w", length, MaxURLLength, ErrPayloadTooLarge)
+	}
+
+	if len(n.Devices) == 0 {
+		c.logger().Debug("[Send] No devices given. Acquiring devices...")
+		registered, err := c.CachedDevices(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(registered) == 0 {
+			return nil, fmt.Errorf("[Send] user has no registered devices: %w", ErrNotFound)
+		}
+		for _, device := range registered {
+			n.Devices = append(n.Devices, device.ID)
+		}
+	}
+
+	if length := utf8.RuneCountInString(n.Text); length > MaxTextLength {
+		switch n.overflow(c) {
+		case OverflowTruncate:
+			n.Text = truncateText(n.Text, MaxTextLength)
+		case OverflowSplit:
+			return c.sendParts(ctx, n)
+		default:
+			return nil, fmt.Errorf("[Send] text of %v characters is longer than %v: %w", length, MaxTextLength, ErrPayloadTooLarge)
+		}
+	}
+
+	return c.send(ctx, n)
+}
+
+// send sends n, which has been validated and has its devices set.
+func (c *Client) send(ctx context.Context, n Notification) (*SendResult, error) {
+	kind := n.kind()
+	devices := n.Devices
+
+	resource, err := c.BaseURL.Parse("notifications/" + string(kind))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		formData  io.Reader
+		imageBody *streamBody
+		imageFit  *ImageFit
+	)
+	if kind == KindImage {
+		imageBody, imageFit, err = n.Image.body(devices, n.Silent)
+		if imageFit != nil && imageFit.Resized() {
+			c.logger().Info("[Send] Image downscaled to fit the size limit", "from", fmt.Sprintf("%vx%v", imageFit.OriginalWidth, imageFit.OriginalHeight), "to", fmt.Sprintf("%vx%v", imageFit.Width, imageFit.Height))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("[Send] %w", err)
+		}
+		defer imageBody.Close()
+		formData = imageBody
+	} else {
+		sendData, err := json.Marshal(n.payload(devices))
+		if err != nil {
+			return nil, errors.New("[Send] unable to create form data to send")
+		}
+		formData = bytes.NewReader(sendData)
+	}
+
+	resp, err := c.request(ctx, "PUT", resource.String(), formData)
+
+	// An image that could not be read, e.g. one found to be too large only while
+	// it was sent, fails the request with a less useful error.
+	if imageBody != nil && imageBody.Err() != nil {
+		if err == nil {
+			resp.Body.Close()
+		}
+		return nil, fmt.Errorf("[Send] %w", imageBody.Err())
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var sResp serverRespSuccess
+	err = json.NewDecoder(resp.Body).Decode(&sResp)
+	if err != nil {
+		return nil, fmt.Errorf("[Send] unable to decode response body as JSON: %v", err.Error())
+	}
+
+	result := newSendResult(devices, sResp)
+	result.Image = imageFit
+	c.logger().Info("[Send] Notification sent", "kind", kind, "delivered", len(result.Delivered), "failed", len(result.Failed))
+	c.logger().Debug("[Send] Delivery per device", "delivered", result.Delivered, "failed", result.FailedIDs())
+
+	return result, result.err("Send")
+}
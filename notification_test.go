@@ -0,0 +1,142 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+
+	invalid := []Notification{
+		{},
+		{Kind: KindText, URL: "https://example.com"},
+		{Kind: KindURL, Text: "hello world"},
+		{Kind: KindNotification, Text: "hello world"},
+		{Kind: KindImage, Text: "hello world"},
+		{Kind: "video", Text: "hello world"},
+		{Text: "hello world", Image: &Image{Path: "image.png"}},
+	}
+
+	for _, n := range invalid {
+		_, err := pn.Send(context.Background(), n)
+		assert.ErrorIs(err, ErrInvalidNotification, "[TestSendValidation] Expected %+v to be rejected", n)
+	}
+}
+
+func TestSendEndpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var got struct {
+		endpoint string
+		body     map[string]interface{}
+	}
+	handler.HandleFunc("/notifications/", func(w http.ResponseWriter, r *http.Request) {
+		got.endpoint = r.URL.Path
+		got.body = nil
+		json.NewDecoder(r.Body).Decode(&got.body)
+		fmt.Fprint(w, `{"success": [], "error": []}`)
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+
+	imagePath := filepath.Join(t.TempDir(), "pnctl.png")
+	assert.NoError(os.WriteFile(imagePath, []byte("\x89PNG\r\n\x1a\n"), 0600))
+
+	tests := []struct {
+		notification Notification
+		endpoint     string
+	}{
+		{Notification{Text: "hello world"}, "/notifications/text"},
+		{Notification{URL: "https://example.com"}, "/notifications/url"},
+		{Notification{Text: "hello world", URL: "https://example.com"}, "/notifications/notification"},
+		{Notification{Image: &Image{Path: imagePath}}, "/notifications/image"},
+	}
+
+	for _, test := range tests {
+		test.notification.Devices = []string{"abcd"}
+		test.notification.Silent = true
+
+		_, err := pn.Send(context.Background(), test.notification)
+		assert.NoError(err, "[TestSendEndpoint] Expected %+v to be sent", test.notification)
+		assert.Equal(test.endpoint, got.endpoint, "[TestSendEndpoint] Expected notification to be sent to the endpoint of its kind")
+		assert.Equal([]interface{}{"abcd"}, got.body["devices"], "[TestSendEndpoint] Expected the given devices to be sent")
+		assert.Equal(true, got.body["silent"], "[TestSendEndpoint] Expected silent to be sent")
+	}
+
+	_, err := pn.SendNotification("hello world", "", []string{"abcd"}, false)
+	assert.NoError(err, "[TestSendEndpoint] Expected SendNotification to accept a text without a URL")
+	assert.Equal("/notifications/text", got.endpoint, "[TestSendEndpoint] Expected a text-only notification to be sent as text")
+}
+
+func TestSendDiscoversDevices(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	handler.HandleFunc("/devices", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": "abcd", "title": "Pixel", "model": "Pixel 6", "image": ""}, {"id": "efgh", "title": "iPad", "model": "iPad Air", "image": ""}]`)
+	})
+
+	var devices []string
+	for _, endpoint := range []string{"/notifications/text", "/notifications/url"} {
+		handler.HandleFunc(endpoint, func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Devices []string `json:"devices"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			devices = body.Devices
+			fmt.Fprint(w, `{"success": [], "error": []}`)
+		})
+	}
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+
+	_, err := pn.SendURL("https://example.com", nil, false)
+	assert.NoError(err)
+	assert.Equal([]string{"abcd", "efgh"}, devices, "[TestSendDiscoversDevices] Expected URL notification to be sent to all registered devices")
+
+	_, err = pn.SendText("hello world", nil, false)
+	assert.NoError(err)
+	assert.Equal([]string{"abcd", "efgh"}, devices, "[TestSendDiscoversDevices] Expected text notification to be sent to all registered devices")
+}
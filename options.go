@@ -0,0 +1,198 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Option configures a Client created with New.
+type Option func(*Client) error
+
+// New creates a new PushNotifier API client for the given package name and API
+// token, configured by opts. Without options it uses a default http.Client, has
+// no App Token, does not log, retry or rate limit requests.
+//
+// For more information on pushnotifier.de: https://api.pushnotifier.de/v2/doc/
+func New(packageName, apiToken string, opts ...Option) (*Client, error) {
+	baseURL, _ := url.Parse(DefaultBaseURL)
+
+	c := &Client{
+		client:      &http.Client{},
+		APIToken:    apiToken,
+		BaseURL:     baseURL,
+		PackageName: packageName,
+		Devices:     make([]string, 0),
+		Logger:      NewNopLogger(),
+
+		deviceCacheTTL: DefaultDeviceCacheTTL,
+	}
+
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.tokenStore != nil {
+		appToken, expiresAt, err := c.tokenStore.Load()
+		if err != nil {
+			return nil, fmt.Errorf("[New] unable to load App Token from token store: %w", err)
+		}
+		if appToken != "" {
+			c.SetToken(appToken, expiresAt)
+		}
+	}
+
+	return c, nil
+}
+
+// WithHTTPClient makes the client send requests with httpClient instead of a default http.Client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) error {
+		if httpClient == nil {
+			return errors.New("[WithHTTPClient] http client is nil")
+		}
+		c.client = httpClient
+		return nil
+	}
+}
+
+// WithBaseURL makes the client send requests to baseURL instead of https://api.pushnotifier.de/v2/.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) error {
+		parsedURL, err := ParseBaseURL(baseURL)
+		if err != nil {
+			return fmt.Errorf("[WithBaseURL] %w", err)
+		}
+		c.BaseURL = parsedURL
+		return nil
+	}
+}
+
+// ParseBaseURL parses the base URL of the API, which must be an absolute http
+// or https URL. A trailing slash is added to its path if missing, so that the
+// endpoints resolve below it rather than replacing its last path segment.
+func ParseBaseURL(baseURL string) (*url.URL, error) {
+	parsedURL, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	switch {
+	case parsedURL.Scheme != "http" && parsedURL.Scheme != "https":
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	case parsedURL.Host == "":
+		return nil, fmt.Errorf("invalid base URL %q: host is missing", baseURL)
+	case parsedURL.RawQuery != "" || parsedURL.Fragment != "":
+		return nil, fmt.Errorf("invalid base URL %q: query and fragment are not allowed", baseURL)
+	}
+
+	if !strings.HasSuffix(parsedURL.Path, "/") {
+		parsedURL.Path += "/"
+		if parsedURL.RawPath != "" {
+			parsedURL.RawPath += "/"
+		}
+	}
+	return parsedURL, nil
+}
+
+// WithAppToken sets the App Token obtained by a previous Login and the time it
+// expires at. The client refreshes the token shortly before it expires. A zero
+// expiresAt means the expiry is unknown, and the token is used as is without ever being refreshed.
+func WithAppToken(appToken string, expiresAt time.Time) Option {
+	return func(c *Client) error {
+		if expiresAt.IsZero() {
+			c.SetToken(appToken, -1)
+		} else {
+			c.SetToken(appToken, expiresAt.Unix())
+		}
+		return nil
+	}
+}
+
+// WithLogger makes the client report what it is doing to logger.
+func WithLogger(logger Logger) Option {
+	return func(c *Client) error {
+		c.Logger = logger
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) error {
+		c.userAgent = userAgent
+		return nil
+	}
+}
+
+// WithRetryPolicy makes the client retry failed requests according to policy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		c.RetryPolicy = &policy
+		return nil
+	}
+}
+
+// WithRateLimiter makes the client wait on limiter before every request.
+func WithRateLimiter(limiter Limiter) Option {
+	return func(c *Client) error {
+		c.RateLimiter = limiter
+		return nil
+	}
+}
+
+// WithDeviceCacheTTL makes the client cache devices obtained by GetDevices for
+// ttl. A ttl of zero or less disables caching, so every lookup fetches the devices.
+func WithDeviceCacheTTL(ttl time.Duration) Option {
+	return func(c *Client) error {
+		c.deviceCacheTTL = ttl
+		return nil
+	}
+}
+
+// WithTokenStore makes the client load its App Token from store when created,
+// and save it to store whenever it is obtained by Login or RefreshToken.
+func WithTokenStore(store TokenStore) Option {
+	return func(c *Client) error {
+		c.tokenStore = store
+		return nil
+	}
+}
+
+// WithOverflowPolicy sets what Send does with a text longer than MaxTextLength. It defaults to OverflowError.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(c *Client) error {
+		switch policy {
+		case OverflowError, OverflowTruncate, OverflowSplit:
+			c.overflow = policy
+			return nil
+		}
+		return fmt.Errorf("[WithOverflowPolicy] unknown overflow policy %q", policy)
+	}
+}
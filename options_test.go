@@ -0,0 +1,137 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryTokenStore struct {
+	appToken  string
+	expiresAt int64
+	saves     int
+}
+
+func (m *memoryTokenStore) Load() (string, int64, error) {
+	return m.appToken, m.expiresAt, nil
+}
+
+func (m *memoryTokenStore) Save(appToken string, expiresAt int64) error {
+	m.appToken, m.expiresAt = appToken, expiresAt
+	m.saves++
+	return nil
+}
+
+func TestNewWithOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	handler.HandleFunc("/devices", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("pnctl-test/1.0", r.UserAgent(), "[TestNewWithOptions] Expected configured User-Agent to be sent")
+		assert.Equal("ZZXX11ff", r.Header.Get("X-AppToken"), "[TestNewWithOptions] Expected configured App Token to be sent")
+		fmt.Fprint(w, `[]`)
+	})
+
+	expiresAt := time.Now().Add(24 * time.Hour)
+
+	pn, err := New("dev.myapp.pn", "aabbccdd112233",
+		WithBaseURL(server.URL),
+		WithAppToken("ZZXX11ff", expiresAt),
+		WithUserAgent("pnctl-test/1.0"),
+		WithRetryPolicy(DefaultRetryPolicy()),
+	)
+	if !assert.NoError(err) {
+		return
+	}
+
+	appToken, expiry := pn.Token()
+	assert.Equal("ZZXX11ff", appToken)
+	assert.Equal(expiresAt.Unix(), expiry)
+	assert.Equal(DefaultRetryPolicy().MaxAttempts, pn.RetryPolicy.MaxAttempts)
+
+	_, err = pn.GetDevices()
+	assert.NoError(err)
+}
+
+func TestNewWithInvalidOption(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := New("dev.myapp.pn", "aabbccdd112233", WithBaseURL("://missing-scheme"))
+	assert.Error(err, "[TestNewWithInvalidOption] Expected an invalid base URL to be rejected")
+
+	_, err = New("dev.myapp.pn", "aabbccdd112233", WithHTTPClient(nil))
+	assert.Error(err, "[TestNewWithInvalidOption] Expected a nil http client to be rejected")
+}
+
+func TestParseBaseURL(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, invalid := range []string{"api.pushnotifier.de/v2/", "ftp://api.pushnotifier.de/v2/", "https:///v2/", "https://api.pushnotifier.de/v2/?debug=1"} {
+		_, err := ParseBaseURL(invalid)
+		assert.Error(err, "[TestParseBaseURL] Expected %q to be rejected", invalid)
+	}
+
+	baseURL, err := ParseBaseURL("https://egress.example.com/pushnotifier/v2")
+	assert.NoError(err)
+	resource, _ := baseURL.Parse("devices")
+	assert.Equal("https://egress.example.com/pushnotifier/v2/devices", resource.String(), "[TestParseBaseURL] Expected endpoints to resolve below a base URL without trailing slash")
+
+	baseURL, err = ParseBaseURL("http://127.0.0.1:8085")
+	assert.NoError(err)
+	assert.Equal("http://127.0.0.1:8085/", baseURL.String())
+}
+
+func TestNewWithTokenStore(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	handler.HandleFunc("/user/refresh", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"username": "aUser", "app_token": "RefreshedZZXX11ff", "expires_at": 4102444800}`)
+	})
+
+	store := &memoryTokenStore{appToken: "ZZXX11ff", expiresAt: time.Now().Add(time.Hour).Unix()}
+
+	pn, err := New("dev.myapp.pn", "aabbccdd112233", WithBaseURL(server.URL), WithTokenStore(store))
+	if !assert.NoError(err) {
+		return
+	}
+
+	appToken, _ := pn.Token()
+	assert.Equal("ZZXX11ff", appToken, "[TestNewWithTokenStore] Expected App Token to be loaded from the store")
+
+	assert.NoError(pn.RefreshToken())
+	assert.Equal("RefreshedZZXX11ff", store.appToken, "[TestNewWithTokenStore] Expected refreshed App Token to be saved to the store")
+	assert.Equal(int64(4102444800), store.expiresAt)
+	assert.Equal(1, store.saves)
+}
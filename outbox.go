@@ -0,0 +1,740 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrQueued is wrapped by the error Outbox.Send returns when a notification
+// could not be sent right away, and was stored in the outbox instead.
+var ErrQueued = errors.New("pushnotifier: notification queued in outbox")
+
+const (
+	// DefaultDedupWindow is how long a sent notification is remembered to skip queueing it again.
+	DefaultDedupWindow = 10 * time.Minute
+	// DefaultClaimTimeout is how long a notification being sent by Flush is skipped by other flushes.
+	DefaultClaimTimeout = 5 * time.Minute
+)
+
+// Operations recorded in the journal of an Outbox.
+const (
+	opQueue   = "queue"
+	opClaim   = "claim"
+	opAttempt = "attempt"
+	opSent    = "sent"
+	opDrop    = "drop"
+)
+
+type (
+	// Outbox is a durable queue of notifications, kept in an append-only journal
+	// file. Notifications are stored with Enqueue, or by Send when they cannot be
+	// sent right away, and sent in the order they were queued by Flush.
+	//
+	// Every change is appended to the journal and synced to disk before it is
+	// acted upon, and a record left incomplete by a crash is discarded when the
+	// journal is next read, so the outbox survives crashes. A notification is
+	// sent at least once: if the process crashes after sending it, but before
+	// recording that it was sent, it is sent again once its claim times out.
+	//
+	// An Outbox is safe for concurrent use by multiple goroutines, and by multiple
+	// processes if Lock is set.
+	Outbox struct {
+		// MaxAttempts is the number of flushes a notification is tried in before it is dropped. Zero means it is never dropped.
+		MaxAttempts int
+		// DedupWindow is how long a sent notification is remembered, so that queueing it again is skipped.
+		DedupWindow time.Duration
+		// ClaimTimeout is how long Flush may take to send a single notification, during which other flushes skip it.
+		ClaimTimeout time.Duration
+		// Lock, if set, is held while the journal is read or written, e.g. to
+		// share the outbox between processes. It returns a function releasing it.
+		Lock func(ctx context.Context) (release func(), err error)
+
+		path  string
+		owner string
+		mu    sync.Mutex
+	}
+
+	// QueuedNotification is a notification waiting in an Outbox.
+	QueuedNotification struct {
+		ID           string
+		Notification Notification
+		QueuedAt     time.Time
+		// Attempts is the number of flushes that failed to send the notification.
+		Attempts int
+		// LastError is the error of the last failed attempt.
+		LastError string
+
+		key        string
+		claimOwner string
+		claimUntil time.Time
+	}
+
+	// FlushResult summarizes a Flush of an Outbox.
+	FlushResult struct {
+		// Sent is the number of notifications sent.
+		Sent int
+		// Dropped is the number of notifications dropped, as they cannot be sent or ran out of attempts.
+		Dropped int
+		// Remaining is the number of notifications still queued.
+		Remaining int
+	}
+
+	// journalRecord is a line of the journal of an Outbox.
+	journalRecord struct {
+		Op           string               `json:"op"`
+		ID           string               `json:"id"`
+		Key          string               `json:"key,omitempty"`
+		Time         time.Time            `json:"time"`
+		Notification *journalNotification `json:"notification,omitempty"`
+		Attempts     int                  `json:"attempts,omitempty"`
+		Error        string               `json:"error,omitempty"`
+		Owner        string               `json:"owner,omitempty"`
+		Until        *time.Time           `json:"until,omitempty"`
+	}
+
+	// journalNotification is a Notification as stored in the journal, with its image read into memory.
+	journalNotification struct {
+		Kind     Kind           `json:"kind"`
+		Text     string         `json:"text,omitempty"`
+		URL      string         `json:"url,omitempty"`
+		Image    []byte         `json:"image,omitempty"`
+		Name     string         `json:"name,omitempty"`
+		Fit      bool           `json:"fit,omitempty"`
+		Devices  []string       `json:"devices,omitempty"`
+		Silent   bool           `json:"silent,omitempty"`
+		Overflow OverflowPolicy `json:"overflow,omitempty"`
+	}
+
+	// outboxState is the state of an Outbox replayed from its journal.
+	outboxState struct {
+		pending []*QueuedNotification
+		byID    map[string]*QueuedNotification
+		// sent maps the keys of sent notifications to the record of sending them.
+		sent map[string]journalRecord
+		// records is the number of records in the journal.
+		records int
+	}
+)
+
+// NewOutbox creates an Outbox keeping its journal in the file at path, which is created when a notification is first queued.
+func NewOutbox(path string) *Outbox {
+	return &Outbox{
+		DedupWindow:  DefaultDedupWindow,
+		ClaimTimeout: DefaultClaimTimeout,
+		path:         path,
+		owner:        newID(),
+	}
+}
+
+// newID returns a random ID.
+func newID() string {
+	raw := make([]byte, 8)
+	rand.Read(raw)
+	return hex.EncodeToString(raw)
+}
+
+// Enqueue stores n in the outbox without trying to send it. It returns the ID
+// of the queued notification, and whether it was skipped as a duplicate of one
+// that is still queued or was sent within the DedupWindow. An image is read
+// into the outbox, so its source need not exist when it is sent.
+//
+// A text longer than MaxTextLength with n.Overflow set to OverflowSplit is
+// queued as its parts, so that a part that was sent is not sent again. The
+// returned ID is the one of the first part.
+func (o *Outbox) Enqueue(ctx context.Context, n Notification) (id string, duplicate bool, err error) {
+	if err := n.validate(); err != nil {
+		return "", false, fmt.Errorf("[Enqueue] %w", err)
+	}
+
+	n, err = bufferImage(n)
+	if err != nil {
+		return "", false, fmt.Errorf("[Enqueue] %w", err)
+	}
+
+	id, duplicate, err = o.enqueue(ctx, splitNotification(n))
+	if err != nil {
+		return "", false, fmt.Errorf("[Enqueue] %w", err)
+	}
+	return id, duplicate, nil
+}
+
+// enqueue stores parts, which make up a single notification, in the outbox. They
+// are skipped as a duplicate if the first part is still queued or was sent within the DedupWindow.
+func (o *Outbox) enqueue(ctx context.Context, parts []Notification) (id string, duplicate bool, err error) {
+	records := make([]journalRecord, 0, len(parts))
+	for _, part := range parts {
+		stored := newJournalNotification(part)
+		raw, err := json.Marshal(stored)
+		if err != nil {
+			return "", false, err
+		}
+		sum := sha256.Sum256(raw)
+
+		records = append(records, journalRecord{Op: opQueue, ID: newID(), Key: hex.EncodeToString(sum[:]), Time: time.Now(), Notification: stored})
+	}
+
+	err = o.update(ctx, func(state *outboxState, write func(...journalRecord) error) error {
+		key := records[0].Key
+		for _, queued := range state.pending {
+			if queued.key == key {
+				id, duplicate = queued.ID, true
+				return nil
+			}
+		}
+		if sent, ok := state.sent[key]; ok && time.Since(sent.Time) < o.DedupWindow {
+			id, duplicate = sent.ID, true
+			return nil
+		}
+
+		id = records[0].ID
+		return write(records...)
+	})
+	return id, duplicate, err
+}
+
+// splitNotification returns n split into parts if its text is longer than
+// MaxTextLength and n.Overflow is OverflowSplit, or else n alone.
+func splitNotification(n Notification) []Notification {
+	if n.Overflow != OverflowSplit || utf8.RuneCountInString(n.Text) <= MaxTextLength {
+		return []Notification{n}
+	}
+
+	texts := splitText(n.Text, MaxTextLength)
+	parts := make([]Notification, len(texts))
+	for i, text := range texts {
+		parts[i] = n
+		parts[i].Text = text
+	}
+	return parts
+}
+
+// List returns the queued notifications, in the order they are sent.
+func (o *Outbox) List(ctx context.Context) ([]QueuedNotification, error) {
+	var queued []QueuedNotification
+
+	err := o.update(ctx, func(state *outboxState, write func(...journalRecord) error) error {
+		queued = make([]QueuedNotification, 0, len(state.pending))
+		for _, q := range state.pending {
+			queued = append(queued, *q)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[List] %w", err)
+	}
+	return queued, nil
+}
+
+// Drop removes the queued notifications with the given IDs without sending them.
+// The error wraps ErrNotFound if any of them is not queued.
+func (o *Outbox) Drop(ctx context.Context, ids ...string) error {
+	err := o.update(ctx, func(state *outboxState, write func(...journalRecord) error) error {
+		records := make([]journalRecord, 0, len(ids))
+		for _, id := range ids {
+			if _, ok := state.byID[id]; !ok {
+				return fmt.Errorf("no queued notification with ID %q: %w", id, ErrNotFound)
+			}
+			records = append(records, journalRecord{Op: opDrop, ID: id, Time: time.Now(), Error: "dropped"})
+		}
+		return write(records...)
+	})
+	if err != nil {
+		return fmt.Errorf("[Drop] %w", err)
+	}
+	return nil
+}
+
+// Send sends n with c. If that fails with an error that sending it later may
+// fix, e.g. a network or server error, n is queued and the returned error wraps
+// ErrQueued. n is queued with the overflow policy it is sent with, and a text
+// split into parts with OverflowSplit is queued from the part that failed on.
+func (o *Outbox) Send(ctx context.Context, c *Client, n Notification) (*SendResult, error) {
+	n.Overflow = n.overflow(c)
+
+	if err := n.validate(); err != nil {
+		return nil, fmt.Errorf("[Send] %w", err)
+	}
+
+	// A reader is used up by sending, and a file may be gone by the time the
+	// outbox is flushed, so read the image beforehand to still have it to queue.
+	n, err := bufferImage(n)
+	if err != nil {
+		return nil, fmt.Errorf("[Send] %w", err)
+	}
+
+	parts := splitNotification(n)
+	if len(parts) > 1 {
+		c.logger().Info("[Send] Splitting text into parts", "parts", len(parts))
+	}
+
+	results := make([]*SendResult, 0, len(parts))
+	for i, part := range parts {
+		result, err := c.Send(ctx, part)
+		if len(parts) == 1 {
+			if err == nil || !retryableSendError(err) {
+				return result, err
+			}
+		} else {
+			if result != nil {
+				results = append(results, result)
+			}
+			if err == nil {
+				continue
+			}
+			err = fmt.Errorf("part %v/%v: %w", i+1, len(parts), err)
+			if !retryableSendError(err) {
+				return mergePartResults(results, len(parts)), fmt.Errorf("[Send] %w", err)
+			}
+		}
+
+		// ctx may be what made sending fail, so it must not also keep the notification from being queued.
+		queueCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		id, _, queueErr := o.enqueue(queueCtx, parts[i:])
+		if queueErr != nil {
+			return nil, fmt.Errorf("[Send] %v, and queueing it failed: %w", err, queueErr)
+		}
+
+		c.logger().Warn("[Send] Notification queued in outbox", "id", id, "error", err)
+		return nil, fmt.Errorf("[Send] %v: %w", err, ErrQueued)
+	}
+
+	return mergePartResults(results, len(parts)), nil
+}
+
+// retryableSendError reports whether a notification that failed to send with err may be sent later.
+func retryableSendError(err error) bool {
+	switch {
+	case errors.Is(err, ErrInvalidNotification), errors.Is(err, ErrBadRequest), errors.Is(err, ErrPayloadTooLarge):
+		return false
+	case errors.Is(err, ErrNotFound):
+		// The user or a device does not exist, which sending again does not change.
+		return false
+	case errors.Is(err, ErrDeliveryFailed):
+		// The notification was accepted, but no device could be reached.
+		return false
+	}
+	return true
+}
+
+// Flush sends the queued notifications with c, in the order they were queued.
+// It stops at the first notification that fails with an error that sending it
+// later may fix, returning that error, and keeps it queued for the next flush.
+// A notification that cannot be sent, e.g. one that is invalid, is dropped.
+//
+// If another flush is sending the first queued notification, Flush returns
+// without sending anything, so that notifications are never sent out of order.
+func (o *Outbox) Flush(ctx context.Context, c *Client) (FlushResult, error) {
+	var result FlushResult
+
+	for {
+		queued, remaining, err := o.claimNext(ctx)
+		if err != nil {
+			return result, fmt.Errorf("[Flush] %w", err)
+		}
+		result.Remaining = remaining
+		if queued == nil {
+			return result, nil
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, o.ClaimTimeout)
+		_, sendErr := c.Send(sendCtx, queued.Notification)
+		cancel()
+
+		record := journalRecord{ID: queued.ID, Key: queued.key, Time: time.Now()}
+		switch {
+		case sendErr == nil || errors.Is(sendErr, ErrDeliveryFailed):
+			record.Op = opSent
+			result.Sent++
+		case !retryableSendError(sendErr) || o.MaxAttempts > 0 && queued.Attempts+1 >= o.MaxAttempts:
+			record.Op, record.Error = opDrop, sendErr.Error()
+			result.Dropped++
+			c.logger().Warn("[Flush] Dropping notification that cannot be sent", "id", queued.ID, "error", sendErr)
+		default:
+			record.Op, record.Error = opAttempt, sendErr.Error()
+		}
+
+		// Record the outcome even if ctx is done, as the notification may well have been sent.
+		recordCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err = o.update(recordCtx, func(state *outboxState, write func(...journalRecord) error) error {
+			return write(record)
+		})
+		cancel()
+
+		if err != nil {
+			return result, fmt.Errorf("[Flush] %w", err)
+		}
+		if record.Op != opAttempt {
+			result.Remaining--
+			continue
+		}
+		return result, fmt.Errorf("[Flush] %w", sendErr)
+	}
+}
+
+// claimNext claims the first queued notification for sending by this outbox,
+// and returns it along with the number of queued notifications. It returns nil
+// if nothing is queued, or the first notification is claimed by another flush.
+func (o *Outbox) claimNext(ctx context.Context) (*QueuedNotification, int, error) {
+	var (
+		next      *QueuedNotification
+		remaining int
+	)
+
+	err := o.update(ctx, func(state *outboxState, write func(...journalRecord) error) error {
+		remaining = len(state.pending)
+		if remaining == 0 {
+			return nil
+		}
+
+		first := state.pending[0]
+		if first.claimOwner != "" && first.claimOwner != o.owner && time.Now().Before(first.claimUntil) {
+			return nil
+		}
+
+		next = first
+		until := time.Now().Add(o.ClaimTimeout)
+		return write(journalRecord{Op: opClaim, ID: first.ID, Time: time.Now(), Owner: o.owner, Until: &until})
+	})
+	return next, remaining, err
+}
+
+// update replays the journal and calls fn with its state and a function
+// appending records to it, while holding the lock of the outbox. The journal
+// is compacted afterwards, if it is mostly made up of records no longer needed.
+func (o *Outbox) update(ctx context.Context, fn func(state *outboxState, write func(...journalRecord) error) error) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.Lock != nil {
+		release, err := o.Lock(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	file, err := os.OpenFile(o.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	state, err := replayJournal(file)
+	if err != nil {
+		return err
+	}
+
+	write := func(records ...journalRecord) error {
+		var buf bytes.Buffer
+		for _, record := range records {
+			line, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&buf, "%08x %s\n", crc32.ChecksumIEEE(line), line)
+			state.apply(record)
+		}
+
+		if _, err := file.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		return file.Sync()
+	}
+
+	if err := fn(state, write); err != nil {
+		return err
+	}
+
+	// Compaction replaces the file, which must be closed first on some platforms.
+	if state.records > 2*(len(state.pending)+len(state.sent))+100 {
+		file.Close()
+		file = nil
+		return o.compact(state)
+	}
+	return nil
+}
+
+// compact replaces the journal with one holding only what state still needs:
+// the queued notifications, and the sent ones within the DedupWindow.
+func (o *Outbox) compact(state *outboxState) error {
+	var buf bytes.Buffer
+	writeRecord := func(record journalRecord) error {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "%08x %s\n", crc32.ChecksumIEEE(line), line)
+		return nil
+	}
+
+	for _, sent := range state.sent {
+		if time.Since(sent.Time) < o.DedupWindow {
+			if err := writeRecord(sent); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, queued := range state.pending {
+		record := journalRecord{Op: opQueue, ID: queued.ID, Key: queued.key, Time: queued.QueuedAt, Notification: newJournalNotification(queued.Notification), Attempts: queued.Attempts, Error: queued.LastError}
+		if err := writeRecord(record); err != nil {
+			return err
+		}
+		if queued.claimOwner != "" {
+			until := queued.claimUntil
+			if err := writeRecord(journalRecord{Op: opClaim, ID: queued.ID, Time: time.Now(), Owner: queued.claimOwner, Until: &until}); err != nil {
+				return err
+			}
+		}
+	}
+
+	tmpPath := o.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, o.path)
+}
+
+// replayJournal reads the records of the journal in file and returns the state
+// they result in. A last record left incomplete by a crash is cut off the file,
+// leaving it positioned at its end for appending.
+func replayJournal(file *os.File) (*outboxState, error) {
+	state := &outboxState{
+		byID: make(map[string]*QueuedNotification),
+		sent: make(map[string]journalRecord),
+	}
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Anything after the last newline is a record whose write was interrupted.
+			if len(line) > 0 {
+				if err := file.Truncate(offset); err != nil {
+					return nil, err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		record, ok := parseJournalLine(line)
+		if !ok {
+			// Only the last record can have been left incomplete by a crash.
+			if _, err := reader.Peek(1); err == io.EOF {
+				if err := file.Truncate(offset); err != nil {
+					return nil, err
+				}
+				break
+			}
+			return nil, fmt.Errorf("outbox journal %v is corrupted at line %v", file.Name(), lineNumber)
+		}
+
+		offset += int64(len(line))
+		state.apply(record)
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// parseJournalLine parses a line of the journal, made up of the CRC-32 checksum of a record and the record as JSON.
+func parseJournalLine(line []byte) (journalRecord, bool) {
+	var record journalRecord
+
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	i := bytes.IndexByte(line, ' ')
+	if i < 0 || fmt.Sprintf("%08x", crc32.ChecksumIEEE(line[i+1:])) != string(line[:i]) {
+		return record, false
+	}
+	return record, json.Unmarshal(line[i+1:], &record) == nil
+}
+
+// apply updates state with record.
+func (s *outboxState) apply(record journalRecord) {
+	s.records++
+
+	switch record.Op {
+	case opQueue:
+		queued := &QueuedNotification{
+			ID:           record.ID,
+			Notification: record.Notification.notification(),
+			QueuedAt:     record.Time,
+			Attempts:     record.Attempts,
+			LastError:    record.Error,
+			key:          record.Key,
+		}
+		s.pending = append(s.pending, queued)
+		s.byID[queued.ID] = queued
+		return
+	case opSent:
+		s.sent[record.Key] = journalRecord{Op: opSent, ID: record.ID, Key: record.Key, Time: record.Time}
+	}
+
+	queued, ok := s.byID[record.ID]
+	if !ok {
+		return
+	}
+
+	switch record.Op {
+	case opClaim:
+		queued.claimOwner, queued.claimUntil = record.Owner, time.Time{}
+		if record.Until != nil {
+			queued.claimUntil = *record.Until
+		}
+	case opAttempt:
+		queued.Attempts++
+		queued.LastError = record.Error
+		queued.claimOwner, queued.claimUntil = "", time.Time{}
+	case opSent, opDrop:
+		delete(s.byID, record.ID)
+		for i, pending := range s.pending {
+			if pending.ID == record.ID {
+				s.pending = append(s.pending[:i], s.pending[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// bufferImage returns n with its image read into memory, so that it can be
+// read more than once, e.g. to queue it after sending it failed.
+func bufferImage(n Notification) (Notification, error) {
+	if n.Image == nil || n.Image.Data != nil {
+		return n, nil
+	}
+
+	src, name, _, err := n.Image.open()
+	if err != nil {
+		return n, err
+	}
+	defer src.Close()
+
+	limit := int64(MaxImageSize)
+	if n.Image.Fit {
+		limit = maxFitInputSize
+	}
+
+	data, err := io.ReadAll(io.LimitReader(src, limit+1))
+	if err != nil {
+		return n, err
+	}
+	if int64(len(data)) > limit {
+		return n, fmt.Errorf("image is too large to be queued: %w", ErrPayloadTooLarge)
+	}
+
+	n.Image = &Image{Data: data, Name: name, Fit: n.Image.Fit}
+	return n, nil
+}
+
+// newJournalNotification returns n, with its image buffered by bufferImage, as stored in the journal.
+func newJournalNotification(n Notification) *journalNotification {
+	stored := &journalNotification{
+		Kind:     n.kind(),
+		Text:     n.Text,
+		URL:      n.URL,
+		Devices:  n.Devices,
+		Silent:   n.Silent,
+		Overflow: n.Overflow,
+	}
+	if n.Image != nil {
+		stored.Image, stored.Name, stored.Fit = n.Image.Data, n.Image.Name, n.Image.Fit
+	}
+	return stored
+}
+
+// notification returns the Notification stored as n.
+func (n *journalNotification) notification() Notification {
+	if n == nil {
+		return Notification{}
+	}
+
+	notification := Notification{
+		Kind:     n.Kind,
+		Text:     n.Text,
+		URL:      n.URL,
+		Devices:  n.Devices,
+		Silent:   n.Silent,
+		Overflow: n.Overflow,
+	}
+	if n.Image != nil {
+		notification.Image = &Image{Data: n.Image, Name: n.Name, Fit: n.Fit}
+	}
+	return notification
+}
+
+// Summary returns a short description of the queued notification, e.g. for listing it.
+func (q QueuedNotification) Summary() string {
+	n := q.Notification
+	switch {
+	case n.Image != nil:
+		return fmt.Sprintf("image %v (%v bytes)", n.Image.Name, len(n.Image.Data))
+	case n.Text != "" && n.URL != "":
+		return fmt.Sprintf("%v <%v>", strings.Join(strings.Fields(n.Text), " "), n.URL)
+	case n.URL != "":
+		return n.URL
+	}
+	return strings.Join(strings.Fields(n.Text), " ")
+}
@@ -0,0 +1,340 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxFlush(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var (
+		down  = true
+		texts []string
+	)
+	handler.HandleFunc("/notifications/text", func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var body struct{ Content string }
+		json.NewDecoder(r.Body).Decode(&body)
+		texts = append(texts, body.Content)
+		fmt.Fprint(w, `{"success": ["abcd"], "error": []}`)
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+
+	outbox := NewOutbox(filepath.Join(t.TempDir(), "outbox.journal"))
+	outbox.MaxAttempts = 3
+
+	for _, text := range []string{"first", "second", "third"} {
+		_, duplicate, err := outbox.Enqueue(ctx, Notification{Text: text, Devices: []string{"abcd"}})
+		assert.NoError(err, "[TestOutboxFlush] Expected notification to be queued")
+		assert.False(duplicate, "[TestOutboxFlush] Expected notification not to be a duplicate")
+	}
+
+	firstID, duplicate, err := outbox.Enqueue(ctx, Notification{Text: "first", Devices: []string{"abcd"}})
+	assert.NoError(err, "[TestOutboxFlush] Expected queueing a duplicate to succeed")
+	assert.True(duplicate, "[TestOutboxFlush] Expected a queued notification to be a duplicate")
+
+	result, err := outbox.Flush(ctx, pn)
+	assert.ErrorIs(err, ErrServer, "[TestOutboxFlush] Expected flush to stop at a server error")
+	assert.Equal(FlushResult{Remaining: 3}, result, "[TestOutboxFlush] Expected nothing to be sent")
+
+	queued, err := outbox.List(ctx)
+	assert.NoError(err, "[TestOutboxFlush] Expected queued notifications to be listed")
+	assert.Len(queued, 3, "[TestOutboxFlush] Expected all notifications to stay queued")
+	assert.Equal(firstID, queued[0].ID, "[TestOutboxFlush] Expected the duplicate to have the ID of the queued notification")
+	assert.Equal(1, queued[0].Attempts, "[TestOutboxFlush] Expected the failed attempt to be recorded")
+	assert.NotEmpty(queued[0].LastError, "[TestOutboxFlush] Expected the error of the failed attempt to be recorded")
+
+	down = false
+	result, err = outbox.Flush(ctx, pn)
+	assert.NoError(err, "[TestOutboxFlush] Expected flush to succeed")
+	assert.Equal(FlushResult{Sent: 3}, result, "[TestOutboxFlush] Expected all notifications to be sent")
+	assert.Equal([]string{"first", "second", "third"}, texts, "[TestOutboxFlush] Expected notifications to be sent in order")
+
+	_, duplicate, err = outbox.Enqueue(ctx, Notification{Text: "third", Devices: []string{"abcd"}})
+	assert.NoError(err, "[TestOutboxFlush] Expected queueing a sent notification to succeed")
+	assert.True(duplicate, "[TestOutboxFlush] Expected a recently sent notification to be a duplicate")
+
+	queued, err = outbox.List(ctx)
+	assert.NoError(err, "[TestOutboxFlush] Expected queued notifications to be listed")
+	assert.Empty(queued, "[TestOutboxFlush] Expected the outbox to be empty")
+
+	// A notification to an unknown device is dropped right away, and does not hold up the rest.
+	handler.HandleFunc("/notifications/url", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	outbox.Enqueue(ctx, Notification{URL: "https://example.com", Devices: []string{"unknown"}})
+	outbox.Enqueue(ctx, Notification{Text: "after unknown", Devices: []string{"abcd"}})
+	result, err = outbox.Flush(ctx, pn)
+	assert.NoError(err, "[TestOutboxFlush] Expected flush not to stop at an unknown device")
+	assert.Equal(FlushResult{Sent: 1, Dropped: 1}, result, "[TestOutboxFlush] Expected the notification to the unknown device to be dropped")
+	assert.Equal("after unknown", texts[len(texts)-1], "[TestOutboxFlush] Expected the next notification to be sent")
+
+	// A notification running out of attempts is dropped, and does not hold up the rest.
+	down = true
+	outbox.Enqueue(ctx, Notification{Text: "fourth", Devices: []string{"abcd"}})
+	for i := 0; i < 3; i++ {
+		result, _ = outbox.Flush(ctx, pn)
+	}
+	assert.Equal(FlushResult{Dropped: 1}, result, "[TestOutboxFlush] Expected notification to be dropped after MaxAttempts")
+}
+
+func TestOutboxJournal(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	journal := filepath.Join(dir, "outbox.journal")
+
+	imagePath := filepath.Join(dir, "pnctl.png")
+	assert.NoError(os.WriteFile(imagePath, []byte("\x89PNG\r\n\x1a\n"), 0600))
+
+	outbox := NewOutbox(journal)
+	textID, _, err := outbox.Enqueue(ctx, Notification{Text: "hello world"})
+	assert.NoError(err, "[TestOutboxJournal] Expected text to be queued")
+	imageID, _, err := outbox.Enqueue(ctx, Notification{Image: &Image{Path: imagePath}})
+	assert.NoError(err, "[TestOutboxJournal] Expected image to be queued")
+	assert.NoError(os.Remove(imagePath))
+
+	_, _, err = outbox.Enqueue(ctx, Notification{Kind: KindURL, Text: "hello world"})
+	assert.ErrorIs(err, ErrInvalidNotification, "[TestOutboxJournal] Expected an invalid notification not to be queued")
+
+	// A record only partly written before a crash is discarded.
+	complete, err := os.ReadFile(journal)
+	assert.NoError(err)
+	for _, torn := range []string{`8a3f01c2 {"op":"queue","id":"ff`, "00000000 {}\n"} {
+		assert.NoError(os.WriteFile(journal, append(append([]byte{}, complete...), torn...), 0600))
+
+		queued, err := NewOutbox(journal).List(ctx)
+		assert.NoError(err, "[TestOutboxJournal] Expected a torn record to be discarded")
+		if assert.Len(queued, 2, "[TestOutboxJournal] Expected queued notifications to be read back") {
+			assert.Equal(textID, queued[0].ID)
+			assert.Equal("hello world", queued[0].Notification.Text)
+			assert.Equal(imageID, queued[1].ID)
+			assert.Equal([]byte("\x89PNG\r\n\x1a\n"), queued[1].Notification.Image.Data, "[TestOutboxJournal] Expected the image to be stored in the journal")
+			assert.Equal("pnctl.png", queued[1].Notification.Image.Name)
+		}
+
+		after, err := os.ReadFile(journal)
+		assert.NoError(err)
+		assert.Equal(complete, after, "[TestOutboxJournal] Expected the torn record to be cut off the journal")
+	}
+
+	assert.ErrorIs(outbox.Drop(ctx, "unknown"), ErrNotFound, "[TestOutboxJournal] Expected dropping an unknown ID to fail")
+	assert.NoError(outbox.Drop(ctx, textID), "[TestOutboxJournal] Expected notification to be dropped")
+	queued, err := outbox.List(ctx)
+	assert.NoError(err)
+	assert.Len(queued, 1, "[TestOutboxJournal] Expected the dropped notification to be removed")
+
+	// Records no longer needed are compacted away.
+	for i := 0; i < 100; i++ {
+		id, _, err := outbox.Enqueue(ctx, Notification{Text: fmt.Sprint(i)})
+		assert.NoError(err)
+		assert.NoError(outbox.Drop(ctx, id))
+	}
+	queued, err = NewOutbox(journal).List(ctx)
+	assert.NoError(err)
+	if assert.Len(queued, 1, "[TestOutboxJournal] Expected compaction to keep queued notifications") {
+		assert.Equal(imageID, queued[0].ID)
+		assert.Equal([]byte("\x89PNG\r\n\x1a\n"), queued[0].Notification.Image.Data)
+	}
+	compacted, err := os.ReadFile(journal)
+	assert.NoError(err)
+	assert.Less(bytes.Count(compacted, []byte("\n")), 200, "[TestOutboxJournal] Expected the journal to be compacted")
+
+	// Corruption anywhere but in the last record is not silently discarded.
+	assert.NoError(os.WriteFile(journal, append([]byte("00000000 {}\n"), complete...), 0600))
+	_, err = outbox.List(ctx)
+	assert.Error(err, "[TestOutboxJournal] Expected a corrupted journal to be reported")
+}
+
+func TestOutboxSend(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	server.Close()
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+
+	outbox := NewOutbox(filepath.Join(t.TempDir(), "outbox.journal"))
+
+	_, err := outbox.Send(ctx, pn, Notification{Text: "hello world", Devices: []string{"abcd"}})
+	assert.ErrorIs(err, ErrQueued, "[TestOutboxSend] Expected notification to be queued when the server is unreachable")
+
+	_, err = outbox.Send(ctx, pn, Notification{Kind: KindURL, Text: "hello world", Devices: []string{"abcd"}})
+	assert.ErrorIs(err, ErrInvalidNotification, "[TestOutboxSend] Expected an invalid notification not to be queued")
+
+	queued, err := outbox.List(ctx)
+	assert.NoError(err)
+	assert.Len(queued, 1, "[TestOutboxSend] Expected only the unsent notification to be queued")
+
+	// A notification being sent by another flush is not sent out of order.
+	other := NewOutbox(outbox.path)
+	_, _, err = other.claimNext(ctx)
+	assert.NoError(err)
+	result, err := outbox.Flush(ctx, pn)
+	assert.NoError(err, "[TestOutboxSend] Expected flush to wait for the other flush")
+	assert.Equal(FlushResult{Remaining: 1}, result, "[TestOutboxSend] Expected nothing to be sent")
+}
+
+func TestOutboxSendImage(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var (
+		down   bool
+		images [][]byte
+	)
+	handler.HandleFunc("/notifications/image", func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var body struct{ Content []byte }
+		json.NewDecoder(r.Body).Decode(&body)
+		images = append(images, body.Content)
+		fmt.Fprint(w, `{"success": ["abcd"], "error": []}`)
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+
+	image := []byte("\x89PNG\r\n\x1a\n")
+	imagePath := filepath.Join(t.TempDir(), "image.png")
+
+	sources := map[string]func() *Image{
+		"reader": func() *Image { return &Image{Reader: bytes.NewReader(image), Name: "stdin.png"} },
+		"path": func() *Image {
+			assert.NoError(os.WriteFile(imagePath, image, 0600))
+			return &Image{Path: imagePath}
+		},
+	}
+	for source, newImage := range sources {
+		down, images = true, nil
+		outbox := NewOutbox(filepath.Join(t.TempDir(), "outbox.journal"))
+
+		_, err := outbox.Send(ctx, pn, Notification{Image: newImage(), Devices: []string{"abcd"}})
+		assert.ErrorIs(err, ErrQueued, "[TestOutboxSendImage] Expected image from %v to be queued after a server error", source)
+		os.Remove(imagePath)
+
+		queued, err := outbox.List(ctx)
+		assert.NoError(err)
+		if assert.Len(queued, 1) && assert.NotNil(queued[0].Notification.Image, "[TestOutboxSendImage] Expected the image from %v to be queued", source) {
+			assert.Equal(image, queued[0].Notification.Image.Data, "[TestOutboxSendImage] Expected the whole image from %v to be queued, although it was read by sending it", source)
+			assert.Equal(".png", filepath.Ext(queued[0].Notification.Image.Name), "[TestOutboxSendImage] Expected the name of the image from %v to be queued", source)
+		}
+
+		down = false
+		result, err := outbox.Flush(ctx, pn)
+		assert.NoError(err, "[TestOutboxSendImage] Expected flush to succeed")
+		assert.Equal(FlushResult{Sent: 1}, result, "[TestOutboxSendImage] Expected the image queued from %v to be sent", source)
+		assert.Equal([][]byte{image}, images, "[TestOutboxSendImage] Expected the server to receive the image from %v", source)
+	}
+}
+
+func TestOutboxOverflow(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var (
+		failAt int
+		texts  []string
+	)
+	handler.HandleFunc("/notifications/text", func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Content string }
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(texts)+1 == failAt {
+			failAt = 0
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		texts = append(texts, body.Content)
+		fmt.Fprint(w, `{"success": ["abcd"], "error": []}`)
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+	assert.NoError(WithOverflowPolicy(OverflowSplit)(pn))
+
+	// The default client flushing the outbox must still split, and not resend the parts already sent.
+	flusher := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	flusher.BaseURL = pn.BaseURL
+
+	outbox := NewOutbox(filepath.Join(t.TempDir(), "outbox.journal"))
+
+	long := strings.Repeat("word ", 1000)
+	failAt = 2
+	_, err := outbox.Send(ctx, pn, Notification{Text: long, Devices: []string{"abcd"}})
+	assert.ErrorIs(err, ErrQueued, "[TestOutboxOverflow] Expected the remaining parts to be queued")
+
+	result, err := outbox.Flush(ctx, flusher)
+	assert.NoError(err, "[TestOutboxOverflow] Expected flush to succeed")
+	assert.Equal(FlushResult{Sent: 2}, result, "[TestOutboxOverflow] Expected only the parts not yet sent to be queued")
+	if assert.Len(texts, 3, "[TestOutboxOverflow] Expected every part to be sent once") {
+		assert.True(strings.HasPrefix(texts[0], "(1/3) "))
+		assert.True(strings.HasPrefix(texts[1], "(2/3) "))
+		assert.True(strings.HasPrefix(texts[2], "(3/3) "))
+	}
+
+	texts = nil
+	_, _, err = outbox.Enqueue(ctx, Notification{Text: long, Devices: []string{"abcd"}, Overflow: OverflowTruncate})
+	assert.NoError(err, "[TestOutboxOverflow] Expected a long text to be queued with its overflow policy")
+	_, err = outbox.Flush(ctx, flusher)
+	assert.NoError(err, "[TestOutboxOverflow] Expected the stored overflow policy to be applied at flush")
+	if assert.Len(texts, 1) {
+		assert.True(strings.HasSuffix(texts[0], "…"), "[TestOutboxOverflow] Expected the text to be truncated")
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MaxTextLength is the longest text, in characters, sent in a single notification.
+	MaxTextLength = 2_000
+	// MaxURLLength is the longest URL, in characters, sent in a notification.
+	MaxURLLength = 2_048
+)
+
+// OverflowPolicy decides what Send does with a text longer than MaxTextLength.
+type OverflowPolicy string
+
+const (
+	// OverflowError rejects the notification with an error wrapping ErrPayloadTooLarge. It is the default.
+	OverflowError OverflowPolicy = "error"
+	// OverflowTruncate cuts the text short, ending it with an ellipsis.
+	OverflowTruncate OverflowPolicy = "truncate"
+	// OverflowSplit sends the text as a sequence of notifications numbered "(1/3)", "(2/3)" and so on, in order.
+	OverflowSplit OverflowPolicy = "split"
+)
+
+// ellipsis marks the end of a truncated text.
+const ellipsis = "…"
+
+// truncateText returns text cut to at most max characters, the last of which is an ellipsis.
+func truncateText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return strings.TrimRightFunc(string(runes[:max-1]), unicode.IsSpace) + ellipsis
+}
+
+// splitText splits text into parts of at most max characters, each starting
+// with its number and the number of parts, e.g. "(1/3) ". Parts are broken at
+// whitespace where possible.
+func splitText(text string, max int) []string {
+	runes := []rune(text)
+
+	// The length of the numbers depends on the number of parts, so grow it until all of them fit.
+	for digits := 1; ; digits++ {
+		chunks := chunkRunes(runes, max-len("(/) ")-2*digits)
+		if len(strconv.Itoa(len(chunks))) > digits {
+			continue
+		}
+
+		parts := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			parts[i] = fmt.Sprintf("(%v/%v) %v", i+1, len(chunks), chunk)
+		}
+		return parts
+	}
+}
+
+// chunkRunes splits runes into chunks of at most size runes, breaking after the
+// last whitespace in the second half of a chunk if there is one.
+func chunkRunes(runes []rune, size int) []string {
+	var chunks []string
+	for len(runes) > size {
+		cut := size
+		for i := size; i > size/2; i-- {
+			if unicode.IsSpace(runes[i-1]) {
+				cut = i
+				break
+			}
+		}
+
+		chunks = append(chunks, strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace))
+		runes = []rune(strings.TrimLeftFunc(string(runes[cut:]), unicode.IsSpace))
+	}
+
+	if len(runes) > 0 || len(chunks) == 0 {
+		chunks = append(chunks, string(runes))
+	}
+	return chunks
+}
+
+// sendParts sends the text of n split into numbered parts, one after another.
+// A device counts as delivered only if it received every part. Sending stops
+// at the first part that fails, returning the result of the parts sent so far.
+func (c *Client) sendParts(ctx context.Context, n Notification) (*SendResult, error) {
+	parts := splitText(n.Text, MaxTextLength)
+	c.logger().Info("[Send] Splitting text into parts", "parts", len(parts))
+
+	results := make([]*SendResult, 0, len(parts))
+	for i, part := range parts {
+		n.Text = part
+
+		result, err := c.send(ctx, n)
+		if result != nil {
+			results = append(results, result)
+		}
+		if err != nil {
+			return mergePartResults(results, len(parts)), fmt.Errorf("[Send] part %v/%v: %w", i+1, len(parts), err)
+		}
+	}
+
+	return mergePartResults(results, len(parts)), nil
+}
+
+// mergePartResults combines the results of sending the parts of a split text.
+func mergePartResults(results []*SendResult, parts int) *SendResult {
+	merged := &SendResult{
+		Delivered: make([]string, 0),
+		Failed:    make([]DeliveryFailure, 0),
+	}
+	if len(results) == 0 {
+		return merged
+	}
+
+	failed := make(map[string]bool)
+	received := make(map[string]int)
+	for i, result := range results {
+		for _, failure := range result.Failed {
+			if !failed[failure.DeviceID] {
+				failed[failure.DeviceID] = true
+				merged.Failed = append(merged.Failed, DeliveryFailure{DeviceID: failure.DeviceID, Reason: fmt.Sprintf("part %v/%v: %v", i+1, parts, failure.Reason)})
+			}
+		}
+		for _, id := range result.Delivered {
+			received[id]++
+		}
+	}
+
+	for _, id := range results[0].Delivered {
+		if !failed[id] && received[id] == len(results) {
+			merged.Delivered = append(merged.Delivered, id)
+		}
+	}
+	return merged
+}
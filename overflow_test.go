@@ -0,0 +1,107 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitText(t *testing.T) {
+	assert := assert.New(t)
+
+	text := strings.Repeat("lorem ipsum ", 30)
+	parts := splitText(text, 100)
+
+	assert.Len(parts, 4, "[TestSplitText] Expected text to be split into 4 parts")
+	for i, part := range parts {
+		assert.LessOrEqual(utf8.RuneCountInString(part), 100, "[TestSplitText] Expected every part to fit")
+		assert.True(strings.HasPrefix(part, fmt.Sprintf("(%v/4) ", i+1)), "[TestSplitText] Expected part %v to be numbered, got %q", i+1, part)
+		assert.False(strings.HasSuffix(part, "lorem ip"), "[TestSplitText] Expected parts to be broken at whitespace")
+	}
+
+	parts = splitText(strings.Repeat("x", 200), 20)
+	assert.Len(parts, 17, "[TestSplitText] Expected numbers with two digits to be accounted for")
+	assert.Equal("(17/17) xxxxxxxx", parts[16])
+
+	assert.Equal("hello…", truncateText("hello world", 6))
+	assert.Equal("hello world", truncateText("hello world", 11))
+}
+
+func TestSendOverflow(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var sent []string
+	handler.HandleFunc("/notifications/text", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Content string `json:"content"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body.Content)
+		fmt.Fprint(w, `{"success": ["abcd"], "error": []}`)
+	})
+
+	text := strings.Repeat("a", MaxTextLength+1)
+	devices := []string{"abcd"}
+
+	pn, _ := New("dev.myapp.pn", "aabbccdd112233", WithBaseURL(server.URL), WithAppToken("ZZXX11ff", time.Time{}))
+	_, err := pn.SendText(text, devices, false)
+	assert.ErrorIs(err, ErrPayloadTooLarge, "[TestSendOverflow] Expected text that is too long to be rejected by default")
+	assert.Empty(sent, "[TestSendOverflow] Expected text that is too long to not be sent")
+
+	_, err = pn.SendURL("https://example.com/"+strings.Repeat("a", MaxURLLength), devices, false)
+	assert.ErrorIs(err, ErrPayloadTooLarge, "[TestSendOverflow] Expected URL that is too long to be rejected")
+
+	pn, _ = New("dev.myapp.pn", "aabbccdd112233", WithBaseURL(server.URL), WithAppToken("ZZXX11ff", time.Time{}), WithOverflowPolicy(OverflowTruncate))
+	_, err = pn.SendText(text, devices, false)
+	assert.NoError(err)
+	if assert.Len(sent, 1) {
+		assert.Equal(MaxTextLength, utf8.RuneCountInString(sent[0]), "[TestSendOverflow] Expected text to be truncated to the maximum length")
+		assert.True(strings.HasSuffix(sent[0], "…"), "[TestSendOverflow] Expected truncated text to end with an ellipsis")
+	}
+
+	sent = nil
+	pn, _ = New("dev.myapp.pn", "aabbccdd112233", WithBaseURL(server.URL), WithAppToken("ZZXX11ff", time.Time{}), WithOverflowPolicy(OverflowSplit))
+	result, err := pn.SendText(strings.Repeat("a", 2*MaxTextLength), devices, false)
+	assert.NoError(err)
+	assert.Equal([]string{"abcd"}, result.Delivered, "[TestSendOverflow] Expected device to have received every part")
+	if assert.Len(sent, 3, "[TestSendOverflow] Expected text to be split into 3 parts") {
+		for i, part := range sent {
+			assert.True(strings.HasPrefix(part, fmt.Sprintf("(%v/3) ", i+1)), "[TestSendOverflow] Expected parts to be sent in order")
+		}
+	}
+
+	_, err = New("dev.myapp.pn", "aabbccdd112233", WithOverflowPolicy("drop"))
+	assert.Error(err, "[TestSendOverflow] Expected unknown overflow policy to be rejected")
+}
@@ -0,0 +1,424 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mavjs/pushnotifier"
+	"github.com/mavjs/pushnotifier/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// aliasesKey is the config key mapping alias names to device IDs.
+	aliasesKey = "ALIASES"
+	// groupsKey is the config key mapping group names to lists of aliases and device IDs.
+	groupsKey = "GROUPS"
+)
+
+// aliasNamePattern restricts alias and group names to what can be used in a
+// comma separated --devices list and as a config key. Names are lower case as
+// viper treats config keys case-insensitively.
+var aliasNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// deviceBook holds the device aliases and groups defined in the config file.
+type deviceBook struct {
+	aliases map[string]string
+	groups  map[string][]string
+}
+
+// loadDeviceBook returns the device aliases and groups from the config read by viper.
+func loadDeviceBook() deviceBook {
+	return deviceBook{
+		aliases: viper.GetStringMapString(aliasesKey),
+		groups:  viper.GetStringMapStringSlice(groupsKey),
+	}
+}
+
+// resolve expands the aliases and groups in names to device IDs. Names that are
+// neither are taken as device IDs. Duplicate devices are only returned once.
+func (b deviceBook) resolve(names []string) []string {
+	ids := make([]string, 0, len(names))
+	seen := make(map[string]bool)
+
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, name := range names {
+		if members, ok := b.groups[strings.ToLower(name)]; ok {
+			for _, member := range members {
+				add(b.resolveAlias(member))
+			}
+			continue
+		}
+		add(b.resolveAlias(name))
+	}
+
+	return ids
+}
+
+// resolveAlias returns the device ID of the alias name, or name itself if it is no alias.
+func (b deviceBook) resolveAlias(name string) string {
+	if id, ok := b.aliases[strings.ToLower(name)]; ok {
+		return id
+	}
+	return name
+}
+
+// validateAliasName checks that name can be used as an alias or group name.
+func validateAliasName(name string) error {
+	if !aliasNamePattern.MatchString(name) {
+		return usageError{fmt.Sprintf("invalid name %q: use lower case letters, digits, '-' and '_' only", name)}
+	}
+	return nil
+}
+
+// lookupDevice finds the registered device with the given ID or title.
+func lookupDevice(ctx context.Context, pn *pushnotifier.Client, device string) (pushnotifier.Device, error) {
+	found, err := pn.DeviceByID(ctx, device)
+	if errors.Is(err, pushnotifier.ErrNotFound) {
+		found, err = pn.DeviceByTitle(ctx, device)
+	}
+	return found, err
+}
+
+// configFilePath returns the path of the config file in use, or of the default one.
+func configFilePath() string {
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		return configFile
+	}
+	return config.GetConfigFilePath()
+}
+
+// settingsMap returns the nested map stored under key in settings, creating it if needed.
+func settingsMap(settings map[string]interface{}, key string) map[string]interface{} {
+	key = strings.ToLower(key)
+
+	m, ok := settings[key].(map[string]interface{})
+	if !ok {
+		m = make(map[string]interface{})
+		settings[key] = m
+	}
+	return m
+}
+
+// aliasCmd represents the devices alias command
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Manage device aliases",
+	Long:  `Manage aliases, e.g. phone-alice, that can be used in place of device IDs.`,
+}
+
+// aliasAddCmd represents the devices alias add command
+var aliasAddCmd = &cobra.Command{
+	Use:   "add NAME DEVICE",
+	Short: "Add or replace a device alias",
+	Long: `Add an alias NAME for DEVICE, given by its ID or title, or replace the device of an existing alias.
+The device is looked up in the devices registered to your account, unless --no-validate is given, in which case DEVICE must be an ID.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		noValidate, err := cmd.Flags().GetBool("no-validate")
+		if err != nil {
+			checkErr(err)
+		}
+
+		name, device := strings.ToLower(args[0]), args[1]
+
+		book := loadDeviceBook()
+		checkErr(validateAliasName(name))
+		if _, ok := book.groups[name]; ok {
+			checkErr(usageError{fmt.Sprintf("%q is already the name of a group", name)})
+		}
+
+		id, title := device, ""
+		if !noValidate {
+			pn, err := newClient()
+			checkErr(err)
+
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+
+			found, err := lookupDevice(ctx, pn, device)
+			checkErr(err)
+			id, title = found.ID, found.Title
+		}
+
+		checkErr(config.UpdateConfigFile(configFilePath(), func(settings map[string]interface{}) error {
+			settingsMap(settings, aliasesKey)[name] = id
+			return nil
+		}))
+
+		if title != "" {
+			fmt.Printf("%v -> %v (%v)\n", name, id, title)
+		} else {
+			fmt.Printf("%v -> %v\n", name, id)
+		}
+	},
+}
+
+// aliasRmCmd represents the devices alias rm command
+var aliasRmCmd = &cobra.Command{
+	Use:     "rm NAME...",
+	Aliases: []string{"remove"},
+	Short:   "Remove device aliases",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		book := loadDeviceBook()
+
+		names := make([]string, 0, len(args))
+		for _, name := range args {
+			name = strings.ToLower(name)
+			if _, ok := book.aliases[name]; !ok {
+				checkErr(fmt.Errorf("no alias named %q", name))
+			}
+			names = append(names, name)
+		}
+
+		checkErr(config.UpdateConfigFile(configFilePath(), func(settings map[string]interface{}) error {
+			aliases := settingsMap(settings, aliasesKey)
+			for _, name := range names {
+				delete(aliases, name)
+			}
+			return nil
+		}))
+
+		for _, name := range names {
+			for group, members := range book.groups {
+				for _, member := range members {
+					if strings.ToLower(member) == name {
+						fmt.Fprintf(os.Stderr, "Warning: group %q still refers to removed alias %q, which is now taken as a device ID\n", group, name)
+					}
+				}
+			}
+		}
+	},
+}
+
+// aliasLsCmd represents the devices alias ls command
+var aliasLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List device aliases",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		book := loadDeviceBook()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ALIAS\tDEVICE ID")
+		names := make([]string, 0, len(book.aliases))
+		for name := range book.aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(w, "%v\t%v\n", name, book.aliases[name])
+		}
+		w.Flush()
+	},
+}
+
+// groupCmd represents the devices group command
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manage named groups of devices",
+	Long:  `Manage groups, e.g. oncall, of aliases and device IDs that can be used in place of device IDs.`,
+}
+
+// groupAddCmd represents the devices group add command
+var groupAddCmd = &cobra.Command{
+	Use:   "add NAME MEMBER...",
+	Short: "Create a group or add members to it",
+	Long: `Create the group NAME, or add members to it if it exists. A MEMBER is an alias, or a device given by its ID or title.
+Devices are looked up in the devices registered to your account, unless --no-validate is given, in which case they must be given by ID.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		noValidate, err := cmd.Flags().GetBool("no-validate")
+		if err != nil {
+			checkErr(err)
+		}
+
+		name := strings.ToLower(args[0])
+
+		book := loadDeviceBook()
+		checkErr(validateAliasName(name))
+		if _, ok := book.aliases[name]; ok {
+			checkErr(usageError{fmt.Sprintf("%q is already the name of an alias", name)})
+		}
+
+		var (
+			pn     *pushnotifier.Client
+			ctx    context.Context
+			cancel context.CancelFunc = func() {}
+		)
+		if !noValidate {
+			pn, err = newClient()
+			checkErr(err)
+
+			ctx, cancel = commandContext(cmd)
+		}
+		defer cancel()
+
+		members := make([]string, 0, len(args)-1)
+		for _, member := range args[1:] {
+			if _, ok := book.aliases[strings.ToLower(member)]; ok {
+				members = append(members, strings.ToLower(member))
+				continue
+			}
+			if _, ok := book.groups[strings.ToLower(member)]; ok {
+				checkErr(usageError{fmt.Sprintf("%q is a group, groups cannot be nested", member)})
+			}
+
+			if noValidate {
+				members = append(members, member)
+				continue
+			}
+
+			found, err := lookupDevice(ctx, pn, member)
+			checkErr(err)
+			members = append(members, found.ID)
+		}
+
+		checkErr(config.UpdateConfigFile(configFilePath(), func(settings map[string]interface{}) error {
+			groups := settingsMap(settings, groupsKey)
+			groups[name] = appendUnique(toStrings(groups[name]), members...)
+			return nil
+		}))
+	},
+}
+
+// groupRmCmd represents the devices group rm command
+var groupRmCmd = &cobra.Command{
+	Use:     "rm NAME [MEMBER...]",
+	Aliases: []string{"remove"},
+	Short:   "Remove a group or members from it",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := strings.ToLower(args[0])
+
+		if _, ok := loadDeviceBook().groups[name]; !ok {
+			checkErr(fmt.Errorf("no group named %q", name))
+		}
+
+		checkErr(config.UpdateConfigFile(configFilePath(), func(settings map[string]interface{}) error {
+			groups := settingsMap(settings, groupsKey)
+			if len(args) == 1 {
+				delete(groups, name)
+				return nil
+			}
+
+			remove := make(map[string]bool)
+			for _, member := range args[1:] {
+				remove[strings.ToLower(member)] = true
+			}
+
+			kept := make([]string, 0)
+			for _, member := range toStrings(groups[name]) {
+				if !remove[strings.ToLower(member)] {
+					kept = append(kept, member)
+				}
+			}
+			groups[name] = kept
+			return nil
+		}))
+	},
+}
+
+// groupLsCmd represents the devices group ls command
+var groupLsCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List groups of devices",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		book := loadDeviceBook()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "GROUP\tMEMBERS\tDEVICE IDS")
+		names := make([]string, 0, len(book.groups))
+		for name := range book.groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(w, "%v\t%v\t%v\n", name, strings.Join(book.groups[name], ","), strings.Join(book.resolve([]string{name}), ","))
+		}
+		w.Flush()
+	},
+}
+
+// toStrings converts a list read from the config file to a slice of strings.
+func toStrings(value interface{}) []string {
+	switch list := value.(type) {
+	case []string:
+		return list
+	case []interface{}:
+		strs := make([]string, 0, len(list))
+		for _, item := range list {
+			strs = append(strs, fmt.Sprint(item))
+		}
+		return strs
+	}
+	return nil
+}
+
+// appendUnique appends the items to list that it does not contain yet.
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		found := false
+		for _, existing := range list {
+			if existing == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func init() {
+	devicesCmd.AddCommand(aliasCmd)
+	aliasCmd.AddCommand(aliasAddCmd, aliasRmCmd, aliasLsCmd)
+
+	devicesCmd.AddCommand(groupCmd)
+	groupCmd.AddCommand(groupAddCmd, groupRmCmd, groupLsCmd)
+
+	aliasAddCmd.Flags().Bool("no-validate", false, "Do not check that the device is registered to your account")
+	groupAddCmd.Flags().Bool("no-validate", false, "Do not check that the devices are registered to your account")
+}
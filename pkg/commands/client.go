@@ -0,0 +1,179 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mavjs/pushnotifier"
+	"github.com/mavjs/pushnotifier/pkg/config"
+	"github.com/spf13/viper"
+)
+
+// envTokenStore is the config.FileTokenStore of the config file, except that
+// the APP_TOKEN and APP_TOKEN_EXPIRY environment variables take precedence over it.
+// Unlike viper, which is not safe for concurrent use, it may be used by
+// whatever goroutine refreshes the App Token, e.g. while pnctl serve handles requests.
+type envTokenStore struct {
+	*config.FileTokenStore
+}
+
+func (s envTokenStore) Load() (string, int64, error) {
+	appToken, ok := os.LookupEnv(config.AppTokenKey)
+	if !ok {
+		return s.FileTokenStore.Load()
+	}
+
+	expiry, ok := os.LookupEnv(config.AppTokenExpiryKey)
+	if !ok {
+		return appToken, -1, nil
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid %v %q: %w", config.AppTokenExpiryKey, expiry, err)
+	}
+	return appToken, expiresAt, nil
+}
+
+// Config keys of the connection to the API.
+const (
+	// baseURLKey is the base URL of the API, also set by --base-url.
+	baseURLKey = "BASE_URL"
+	// proxyKey is the URL of the proxy requests are sent through, instead of
+	// the one set by the HTTPS_PROXY and HTTP_PROXY environment variables.
+	proxyKey = "PROXY"
+	// caBundleKey is the path to a PEM file of CA certificates trusted in addition to those of the system.
+	caBundleKey = "CA_BUNDLE"
+)
+
+// newClient creates a pushnotifier client from the registered authentication
+// details and settings in the config file. opts are applied after those.
+func newClient(opts ...pushnotifier.Option) (*pushnotifier.Client, error) {
+	packageName := viper.GetString("PACKAGE_NAME")
+	apiToken := viper.GetString("API_TOKEN")
+	appToken := viper.GetString(config.AppTokenKey)
+
+	if packageName == "" || apiToken == "" {
+		return nil, errors.New("no package name or api token can be found. please use `register` command to register")
+	}
+
+	httpClient, err := newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	clientOpts := []pushnotifier.Option{pushnotifier.WithLogger(logger), pushnotifier.WithHTTPClient(httpClient)}
+
+	if baseURL := viper.GetString(baseURLKey); baseURL != "" {
+		if _, err := pushnotifier.ParseBaseURL(baseURL); err != nil {
+			return nil, usageError{err.Error()}
+		}
+		clientOpts = append(clientOpts, pushnotifier.WithBaseURL(baseURL))
+	}
+
+	// Keep the App Token in the config file up to date whenever the client obtains a new one.
+	// The App Token of another API, e.g. a mock-server, is kept apart from the one for pushnotifier.de.
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		store := config.NewFileTokenStore(configFile)
+		store.BaseURL = tokenBaseURL()
+		clientOpts = append(clientOpts, pushnotifier.WithTokenStore(envTokenStore{store}))
+	} else if appToken != "" {
+		clientOpts = append(clientOpts, pushnotifier.WithAppToken(appToken, time.Time{}))
+	}
+
+	// RATE_LIMIT is shared by all pnctl invocations through a state file guarded by a lock file in the config directory.
+	if rate := viper.GetFloat64("RATE_LIMIT"); rate > 0 {
+		configDir, err := config.GetConfigDirPath()
+		if err != nil {
+			return nil, err
+		}
+
+		limiter := config.NewFileRateLimiter(configDir, rate, viper.GetInt("RATE_BURST"))
+		limiter.FailFast = viper.GetBool("RATE_LIMIT_FAIL_FAST")
+		clientOpts = append(clientOpts, pushnotifier.WithRateLimiter(limiter))
+	}
+
+	return pushnotifier.New(packageName, apiToken, append(clientOpts, opts...)...)
+}
+
+// tokenBaseURL returns the base URL the App Token in the config file is kept
+// under, which is empty for the API of pushnotifier.de, see config.FileTokenStore.
+func tokenBaseURL() string {
+	baseURL := viper.GetString(baseURLKey)
+	if baseURL == "" {
+		return ""
+	}
+
+	parsedURL, err := pushnotifier.ParseBaseURL(baseURL)
+	if err != nil || parsedURL.String() == pushnotifier.DefaultBaseURL {
+		return ""
+	}
+	return parsedURL.String()
+}
+
+// newHTTPClient creates the http.Client used to reach the API, configured by
+// the PROXY and CA_BUNDLE settings.
+func newHTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxy := viper.GetString(proxyKey); proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, usageError{fmt.Sprintf("invalid proxy URL %q", proxy)}
+		}
+
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, usageError{fmt.Sprintf("invalid proxy URL %q: scheme must be http, https or socks5", proxy)}
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if caBundle := viper.GetString(caBundleKey); caBundle != "" {
+		pem, err := os.ReadFile(caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %w", err)
+		}
+
+		// Without access to the system's certificates, e.g. on Windows before Go 1.18, only the bundle is trusted.
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %v", caBundle)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
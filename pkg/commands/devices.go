@@ -0,0 +1,175 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mavjs/pushnotifier"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// devicesCmd represents the devices command
+var devicesCmd = &cobra.Command{
+	Use:   "devices",
+	Short: "Manage devices, device aliases and groups",
+	Long: `Manage the devices registered to your account, and the aliases and groups of them defined in the config file.
+Aliases and groups can be used in place of device IDs wherever devices are given, e.g. send --devices oncall,phone-alice`,
+}
+
+// devicesListCmd represents the devices list command
+var devicesListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the devices registered to your account",
+	Long: `List the devices registered to your account with their ID, title, model and aliases.
+Use --output to print them as json, yaml or csv, or as bare IDs one per line (ids) for scripts, e.g.
+
+  pnctl devices list --output json | jq -r '.[] | select(.model | test("Pixel")) | .id'`,
+	Args: cobra.NoArgs,
+	Run:  runDevicesList,
+}
+
+// getdevicesCmd is the former name of the devices list command, kept for compatibility.
+var getdevicesCmd = &cobra.Command{
+	Use:    "getdevices",
+	Short:  "Get connected devices",
+	Long:   `Get connected devices to your account and show them for convenience and or later used for sending notifications.`,
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	Run:    runDevicesList,
+}
+
+// deviceListItem is a device as printed by the devices list command.
+type deviceListItem struct {
+	ID      string   `json:"id" yaml:"id"`
+	Title   string   `json:"title" yaml:"title"`
+	Model   string   `json:"model" yaml:"model"`
+	Image   string   `json:"image" yaml:"image"`
+	Aliases []string `json:"aliases" yaml:"aliases"`
+}
+
+func runDevicesList(cmd *cobra.Command, args []string) {
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		checkErr(err)
+	}
+
+	// Reject unknown formats before making any request.
+	if err := printDevices(io.Discard, output, nil, deviceBook{}); err != nil {
+		checkErr(err)
+	}
+
+	pn, err := newClient()
+	checkErr(err)
+
+	ctx, cancel := commandContext(cmd)
+	defer cancel()
+
+	devices, err := pn.GetDevicesContext(ctx)
+	checkErr(err)
+
+	checkErr(printDevices(os.Stdout, output, devices, loadDeviceBook()))
+}
+
+// printDevices writes devices, with their aliases from book, to w in the given output format.
+func printDevices(w io.Writer, output string, devices []pushnotifier.Device, book deviceBook) error {
+	aliases := make(map[string][]string)
+	for name, id := range book.aliases {
+		aliases[id] = append(aliases[id], name)
+	}
+
+	items := make([]deviceListItem, 0, len(devices))
+	for _, device := range devices {
+		deviceAliases := aliases[device.ID]
+		if deviceAliases == nil {
+			deviceAliases = make([]string, 0)
+		}
+		sort.Strings(deviceAliases)
+
+		items = append(items, deviceListItem{
+			ID:      device.ID,
+			Title:   device.Title,
+			Model:   device.Model,
+			Image:   device.Image,
+			Aliases: deviceAliases,
+		})
+	}
+
+	switch output {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tTITLE\tMODEL\tALIAS")
+		for _, item := range items {
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", item.ID, item.Title, item.Model, strings.Join(item.Aliases, ","))
+		}
+		return tw.Flush()
+
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(items)
+
+	case "yaml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(items); err != nil {
+			return err
+		}
+		return encoder.Close()
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "title", "model", "image", "aliases"})
+		for _, item := range items {
+			cw.Write([]string{item.ID, item.Title, item.Model, item.Image, strings.Join(item.Aliases, ",")})
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case "ids":
+		for _, item := range items {
+			fmt.Fprintln(w, item.ID)
+		}
+		return nil
+	}
+
+	return usageError{fmt.Sprintf("unknown output format %q: use table, json, yaml, csv or ids", output)}
+}
+
+func init() {
+	rootCmd.AddCommand(devicesCmd)
+	devicesCmd.AddCommand(devicesListCmd)
+	rootCmd.AddCommand(getdevicesCmd)
+
+	devicesListCmd.Flags().StringP("output", "o", "table", "Output format: table, json, yaml, csv or ids")
+	// getdevices used to print bare IDs, which scripts may rely on.
+	getdevicesCmd.Flags().StringP("output", "o", "ids", "Output format: table, json, yaml, csv or ids")
+}
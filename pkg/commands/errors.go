@@ -0,0 +1,102 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mavjs/pushnotifier"
+)
+
+// Exit codes used by pnctl so that scripts can tell failures apart.
+const (
+	exitOK              = 0
+	exitError           = 1
+	exitUsage           = 2
+	exitUnauthorized    = 3
+	exitNotFound        = 4
+	exitPayloadTooLarge = 5
+	exitRateLimited     = 6
+	exitServerError     = 7
+	exitTimeout         = 8
+	exitDeliveryFailed  = 9
+	exitInterrupted     = 130
+)
+
+// errPartialDelivery is reported by `send --fail-on-partial` when some devices did not receive the notification.
+var errPartialDelivery = errors.New("notification was not delivered to every device")
+
+// usageError marks errors caused by invalid command line usage.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+// exitCode maps an error returned by the pushnotifier library or a command to a process exit code.
+func exitCode(err error) int {
+	var uErr usageError
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &uErr), errors.Is(err, pushnotifier.ErrInvalidNotification):
+		return exitUsage
+	case errors.Is(err, pushnotifier.ErrUnauthorized), errors.Is(err, pushnotifier.ErrForbidden):
+		return exitUnauthorized
+	case errors.Is(err, pushnotifier.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, pushnotifier.ErrPayloadTooLarge):
+		return exitPayloadTooLarge
+	case errors.Is(err, pushnotifier.ErrRateLimited):
+		return exitRateLimited
+	case errors.Is(err, pushnotifier.ErrServer):
+		return exitServerError
+	case errors.Is(err, pushnotifier.ErrDeliveryFailed), errors.Is(err, errPartialDelivery):
+		return exitDeliveryFailed
+	case errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	}
+	return exitError
+}
+
+// checkErr behaves like cobra.CheckErr, but exits with the code matching the
+// given error instead of always exiting with 1.
+func checkErr(msg interface{}) {
+	if msg == nil {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "Error:", msg)
+
+	if err, ok := msg.(error); ok {
+		os.Exit(exitCode(err))
+	}
+	os.Exit(exitError)
+}
@@ -0,0 +1,166 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mavjs/pushnotifier/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+const (
+	// userNameKey is the config key holding the name of the logged in user.
+	userNameKey = "USER_NAME"
+
+	usernameEnv = "PUSHNOTIFIER_USERNAME"
+	passwordEnv = "PUSHNOTIFIER_PASSWORD"
+)
+
+// loginCmd represents the login command
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Obtains an App Token by logging in with username and password",
+	Long: `Logs in to pushnotifier.de on behalf of a user to obtain an App Token, and stores it, its expiry and the username in the config file.
+The password is never stored. An App Token obtained from another base URL, e.g. a mock-server, is stored apart from the one for pushnotifier.de.
+
+The package name and API token must have been registered before, see the register command.
+Username and password are prompted for, unless they are given by the --username flag or the ` + usernameEnv + ` environment variable,
+and the --password-stdin flag or the ` + passwordEnv + ` environment variable respectively:
+
+  echo "$PASSWORD" | pnctl login --username aUser --password-stdin`,
+	Run: func(cmd *cobra.Command, args []string) {
+		username, err := cmd.Flags().GetString("username")
+		if err != nil {
+			checkErr(err)
+		}
+
+		passwordStdin, err := cmd.Flags().GetBool("password-stdin")
+		if err != nil {
+			checkErr(err)
+		}
+
+		stdin := bufio.NewReader(os.Stdin)
+
+		if username == "" {
+			username = os.Getenv(usernameEnv)
+		}
+		if username == "" {
+			if passwordStdin {
+				checkErr(usageError{"--password-stdin requires the username to be given by --username or " + usernameEnv})
+			}
+
+			fmt.Fprint(os.Stderr, "Username: ")
+			username, err = readLine(stdin)
+			checkErr(err)
+		}
+
+		var password string
+		switch {
+		case passwordStdin:
+			password, err = readLine(stdin)
+			checkErr(err)
+		case os.Getenv(passwordEnv) != "":
+			password = os.Getenv(passwordEnv)
+		default:
+			password, err = readPassword("Password: ")
+			checkErr(err)
+		}
+
+		if username == "" || password == "" {
+			checkErr(usageError{"username and password are required"})
+		}
+
+		pn, err := newClient()
+		checkErr(err)
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		checkErr(pn.LoginContext(ctx, username, password))
+
+		// Only the login result is written, as viper also holds settings from flags and the environment, e.g. --base-url.
+		appToken, expiresAt := pn.Token()
+		path := configFilePath()
+
+		store := config.NewFileTokenStore(path)
+		store.BaseURL = tokenBaseURL()
+
+		logger.Info("Writing App Token to config", "path", path)
+		checkErr(store.Save(appToken, expiresAt))
+
+		// The username is shown for the App Token of pushnotifier.de, so logging in to e.g. a mock-server must not replace it.
+		if store.BaseURL == "" {
+			checkErr(config.UpdateConfigFile(path, func(settings map[string]interface{}) error {
+				settings[strings.ToLower(userNameKey)] = username
+				return nil
+			}))
+		}
+
+		fmt.Printf("Logged in as %v\n", username)
+	},
+}
+
+// loggedInUser returns the name of the user stored by the login command, if the
+// App Token in use is the one for pushnotifier.de.
+func loggedInUser() string {
+	if tokenBaseURL() != "" {
+		return ""
+	}
+	return viper.GetString(userNameKey)
+}
+
+// readLine reads a single line from r, without its line ending.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readPassword prompts for a password on the terminal without echoing it.
+func readPassword(prompt string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", usageError{"no terminal to prompt for the password. use --password-stdin or " + passwordEnv}
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+
+	return string(password), err
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+
+	loginCmd.Flags().StringP("username", "u", "", "Username of the pushnotifier.de account to log in as")
+	loginCmd.Flags().Bool("password-stdin", false, "Read the password from stdin instead of prompting for it")
+}
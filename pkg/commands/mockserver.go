@@ -0,0 +1,255 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mavjs/pushnotifier/pkg/config"
+	"github.com/mavjs/pushnotifier/pushnotifiertest"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// mockServerCmd represents the mock-server command
+var mockServerCmd = &cobra.Command{
+	Use:   "mock-server",
+	Short: "Run a fake pushnotifier.de API for local development",
+	Long: `Run a fake of the pushnotifier.de v2 API on a local port, for environments that cannot reach pushnotifier.de.
+It accepts the package name and API token of the config file, and logging in as --username with --password.
+On start, it stores an App Token for its base URL in the config file, apart from the one for pushnotifier.de, so that pnctl works against it without logging in.
+Received notifications are stored in --data-dir, and can be inspected at /_inbox, or as JSON at /_inbox?format=json.
+
+To send to it, point pnctl or the library at its base URL:
+  pnctl mock-server --listen 127.0.0.1:8085
+  pnctl --base-url http://127.0.0.1:8085/v2/ send "hello world"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		listen, err := cmd.Flags().GetString("listen")
+		if err != nil {
+			checkErr(err)
+		}
+
+		dataDir, err := cmd.Flags().GetString("data-dir")
+		if err != nil {
+			checkErr(err)
+		}
+
+		username, err := cmd.Flags().GetString("username")
+		if err != nil {
+			checkErr(err)
+		}
+
+		password, err := cmd.Flags().GetString("password")
+		if err != nil {
+			checkErr(err)
+		}
+
+		if dataDir == "" {
+			configDir, err := config.GetConfigDirPath()
+			checkErr(err)
+			dataDir = filepath.Join(configDir, "mock-server")
+		}
+		checkErr(os.MkdirAll(dataDir, 0700))
+
+		inbox := &mockInbox{path: filepath.Join(dataDir, "notifications.jsonl")}
+
+		fake := pushnotifiertest.NewFake(pushnotifiertest.Config{
+			PackageName: viper.GetString("PACKAGE_NAME"),
+			APIToken:    viper.GetString("API_TOKEN"),
+			Users:       map[string]string{username: password},
+			OnNotification: func(notification pushnotifiertest.Notification) {
+				if err := inbox.add(notification); err != nil {
+					logger.Error("Unable to store notification", "error", err)
+				}
+			},
+		})
+
+		mux := http.NewServeMux()
+		mux.Handle("/_inbox", inbox)
+		mux.Handle("/", fake)
+
+		listener, err := net.Listen("tcp", listen)
+		checkErr(err)
+
+		server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-cmd.Context().Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(ctx)
+		}()
+
+		baseURL := fmt.Sprintf("http://%v/v2/", listener.Addr())
+
+		// Store an App Token for the mock-server, so that pnctl works against it without logging in.
+		store := config.NewFileTokenStore(configFilePath())
+		store.BaseURL = baseURL
+		appToken, expiresAt := fake.IssueToken(username)
+		checkErr(store.Save(appToken, expiresAt.Unix()))
+
+		logger.Info("Serving fake pushnotifier.de API", "base_url", baseURL, "inbox", fmt.Sprintf("http://%v/_inbox", listener.Addr()), "data_dir", dataDir)
+		fmt.Println(baseURL)
+
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			checkErr(err)
+		}
+	},
+}
+
+// mockInbox stores the notifications received by the mock-server in a JSON
+// lines file, and serves them as an HTML page or JSON.
+type mockInbox struct {
+	path string
+	mu   sync.Mutex
+}
+
+// add appends notification to the file.
+func (i *mockInbox) add(notification pushnotifiertest.Notification) error {
+	line, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	file, err := os.OpenFile(i.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// list returns the stored notifications, newest first.
+func (i *mockInbox) list() ([]pushnotifiertest.Notification, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	file, err := os.Open(i.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []pushnotifiertest.Notification{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	notifications := make([]pushnotifiertest.Notification, 0)
+	scanner := bufio.NewScanner(file)
+	// Lines hold base64 encoded images of up to 5 MB.
+	scanner.Buffer(make([]byte, 64*1024), 2*pushnotifiertest.MaxImageSize)
+	for scanner.Scan() {
+		var notification pushnotifiertest.Notification
+		if err := json.Unmarshal(scanner.Bytes(), &notification); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	for left, right := 0, len(notifications)-1; left < right; left, right = left+1, right-1 {
+		notifications[left], notifications[right] = notifications[right], notifications[left]
+	}
+	return notifications, scanner.Err()
+}
+
+func (i *mockInbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	notifications, err := i.list()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(notifications)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := inboxTemplate.Execute(w, notifications); err != nil {
+		logger.Error("Unable to render inbox", "error", err)
+	}
+}
+
+var inboxTemplate = template.Must(template.New("inbox").Funcs(template.FuncMap{
+	"imageURL": func(n pushnotifiertest.Notification) template.URL {
+		contentType := http.DetectContentType(n.Image)
+		if !strings.HasPrefix(contentType, "image/") {
+			return ""
+		}
+		return template.URL("data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(n.Image))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>pnctl mock-server inbox</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: .5em; text-align: left; vertical-align: top; }
+img { max-width: 320px; }
+pre { margin: 0; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>Inbox</h1>
+<p>{{len .}} notifications received. <a href="?format=json">JSON</a></p>
+<table>
+<tr><th>Received</th><th>Kind</th><th>Devices</th><th>Content</th></tr>
+{{range .}}<tr>
+<td>{{.Received.Format "2006-01-02 15:04:05"}}{{if .Silent}}<br>silent{{end}}</td>
+<td>{{.Kind}}</td>
+<td>{{range .Devices}}{{.}}<br>{{end}}</td>
+<td>{{if .Content}}<pre>{{.Content}}</pre>{{end}}{{if .URL}}<a href="{{.URL}}">{{.URL}}</a>{{end}}{{if .Image}}{{.Filename}}<br><img src="{{imageURL .}}" alt="{{.Filename}}">{{end}}</td>
+</tr>{{end}}
+</table>
+</body>
+</html>
+`))
+
+func init() {
+	rootCmd.AddCommand(mockServerCmd)
+
+	mockServerCmd.Flags().String("listen", "127.0.0.1:8085", "Address to listen on")
+	mockServerCmd.Flags().String("data-dir", "", "Directory to store received notifications in (default is mock-server in the config directory)")
+	mockServerCmd.Flags().String("username", pushnotifiertest.DefaultUsername, "Username accepted by the login endpoint")
+	mockServerCmd.Flags().String("password", pushnotifiertest.DefaultPassword, "Password accepted by the login endpoint")
+}
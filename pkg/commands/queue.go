@@ -0,0 +1,248 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mavjs/pushnotifier"
+	"github.com/mavjs/pushnotifier/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// queueCmd represents the queue command
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Manage notifications queued in the outbox",
+	Long: `Manage the outbox, a journal in the config directory holding notifications to send later.
+Notifications are queued by send --queue, or by send --queue-on-failure when they cannot be sent, e.g. while the network is down,
+and sent in the order they were queued by queue flush, e.g. from a cron job:
+
+  */5 * * * * pnctl queue flush --quiet`,
+}
+
+// queueListCmd represents the queue list command
+var queueListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the queued notifications",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			checkErr(err)
+		}
+		if output != "table" && output != "json" {
+			checkErr(usageError{fmt.Sprintf("unknown output format %q: use table or json", output)})
+		}
+
+		outbox, err := openOutbox()
+		checkErr(err)
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		queued, err := outbox.List(ctx)
+		checkErr(err)
+
+		checkErr(printQueue(output, queued))
+	},
+}
+
+// queueFlushCmd represents the queue flush command
+var queueFlushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Send the queued notifications",
+	Long: `Send the queued notifications in the order they were queued.
+Flushing stops at the first notification that fails to send, e.g. as the network is still down, which stays queued for the next flush.
+Notifications that cannot be sent, e.g. as they are too large, or that failed --max-attempts flushes, are dropped.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		maxAttempts, err := cmd.Flags().GetInt("max-attempts")
+		if err != nil {
+			checkErr(err)
+		}
+
+		overflow, err := cmd.Flags().GetString("overflow")
+		if err != nil {
+			checkErr(err)
+		}
+
+		policy, err := parseOverflowPolicy(overflow)
+		checkErr(err)
+
+		outbox, err := openOutbox()
+		checkErr(err)
+		outbox.MaxAttempts = maxAttempts
+
+		pn, err := newClient(pushnotifier.WithOverflowPolicy(policy))
+		checkErr(err)
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		result, err := outbox.Flush(ctx, pn)
+		fmt.Printf("sent %v, dropped %v, remaining %v\n", result.Sent, result.Dropped, result.Remaining)
+		checkErr(err)
+	},
+}
+
+// queueDropCmd represents the queue drop command
+var queueDropCmd = &cobra.Command{
+	Use:   "drop <id>...",
+	Short: "Remove queued notifications without sending them",
+	Run: func(cmd *cobra.Command, args []string) {
+		all, err := cmd.Flags().GetBool("all")
+		if err != nil {
+			checkErr(err)
+		}
+
+		if all == (len(args) > 0) {
+			checkErr(usageError{"provide either the IDs of the notifications to drop or --all"})
+		}
+
+		outbox, err := openOutbox()
+		checkErr(err)
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		ids := args
+		if all {
+			queued, err := outbox.List(ctx)
+			checkErr(err)
+
+			for _, q := range queued {
+				ids = append(ids, q.ID)
+			}
+		}
+
+		checkErr(outbox.Drop(ctx, ids...))
+		logger.Info("Dropped queued notifications", "count", len(ids))
+	},
+}
+
+// queueOnFailureKey makes send and serve queue notifications they cannot send in the outbox, also set by --queue-on-failure.
+const queueOnFailureKey = "QUEUE_ON_FAILURE"
+
+// queueOnFailureEnabled reports whether cmd should queue notifications it cannot send,
+// as set by its --queue-on-failure flag or else by the config file.
+func queueOnFailureEnabled(cmd *cobra.Command) (bool, error) {
+	if cmd.Flags().Changed("queue-on-failure") {
+		return cmd.Flags().GetBool("queue-on-failure")
+	}
+	return viper.GetBool(queueOnFailureKey), nil
+}
+
+// openOutbox returns the outbox kept in the config directory, shared by all
+// pnctl invocations through a lock file next to its journal.
+func openOutbox() (*pushnotifier.Outbox, error) {
+	configDir, err := config.GetConfigDirPath()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(configDir, "outbox.journal")
+	outbox := pushnotifier.NewOutbox(path)
+	outbox.Lock = func(ctx context.Context) (func(), error) {
+		return config.AcquireLock(ctx, path+".lock")
+	}
+	return outbox, nil
+}
+
+// queueListItem is a queued notification as printed by the queue list command.
+type queueListItem struct {
+	ID        string    `json:"id"`
+	QueuedAt  time.Time `json:"queued_at"`
+	Kind      string    `json:"kind"`
+	Devices   []string  `json:"devices"`
+	Summary   string    `json:"summary"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+}
+
+// printQueue prints the queued notifications in the given output format.
+func printQueue(output string, queued []pushnotifier.QueuedNotification) error {
+	items := make([]queueListItem, 0, len(queued))
+	for _, q := range queued {
+		devices := q.Notification.Devices
+		if devices == nil {
+			devices = make([]string, 0)
+		}
+
+		items = append(items, queueListItem{
+			ID:        q.ID,
+			QueuedAt:  q.QueuedAt,
+			Kind:      string(q.Notification.Kind),
+			Devices:   devices,
+			Summary:   q.Summary(),
+			Attempts:  q.Attempts,
+			LastError: q.LastError,
+		})
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(items)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tQUEUED\tKIND\tDEVICES\tATTEMPTS\tSUMMARY\tLAST ERROR")
+	for _, item := range items {
+		devices := strings.Join(item.Devices, ",")
+		if devices == "" {
+			devices = "all"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", item.ID, item.QueuedAt.Local().Format(time.RFC3339), item.Kind, devices, item.Attempts, shorten(item.Summary, 40), item.LastError)
+	}
+	return tw.Flush()
+}
+
+// shorten cuts s to at most n characters, marking the cut with an ellipsis.
+func shorten(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+func init() {
+	rootCmd.AddCommand(queueCmd)
+	queueCmd.AddCommand(queueListCmd)
+	queueCmd.AddCommand(queueFlushCmd)
+	queueCmd.AddCommand(queueDropCmd)
+
+	queueListCmd.Flags().StringP("output", "o", "table", "Output format: table or json")
+	queueFlushCmd.Flags().Int("max-attempts", 10, "Number of flushes a notification may fail in before it is dropped, or 0 to never drop it")
+	queueFlushCmd.Flags().String("overflow", "error", "What to do with a text longer than 2000 characters queued without an overflow policy: error, truncate it with an ellipsis, or split it into numbered notifications")
+	queueDropCmd.Flags().Bool("all", false, "Drop all queued notifications")
+}
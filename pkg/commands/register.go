@@ -61,7 +61,9 @@ var registerCmd = &cobra.Command{
 		if err != nil {
 			cobra.CheckErr(err)
 		}
-		viper.Set("APP_TOKEN", appToken)
+		viper.Set(config.AppTokenKey, appToken)
+		// The expiry of a token entered by hand is unknown, so it is never refreshed.
+		viper.Set(config.AppTokenExpiryKey, -1)
 
 		apiToken, err := terminal.ReadPassword("API Token: ")
 		if err != nil {
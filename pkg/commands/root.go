@@ -22,23 +22,50 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/mavjs/pushnotifier"
 	"github.com/mavjs/pushnotifier/pkg/config"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var cfgFile string
+var (
+	cfgFile string
+	timeout time.Duration
+	verbose bool
+	quiet   bool
+
+	// logger is shared by pnctl and the pushnotifier clients it creates.
+	logger = pushnotifier.NewNopLogger()
+)
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "pnctl",
 	Short: "A brief description of your application",
 	Long: `pnctl - a commandline application that can be used to send different types of notifications to your registered devices.
-You can send text and or url, or image as notifications.`,
+You can send text and or url, or image as notifications.
+
+Exit codes:
+  0    success
+  1    generic failure
+  2    invalid command line usage
+  3    unauthorized: invalid package name, API token, App Token or login credentials
+  4    user or device not found
+  5    payload, e.g. an image, too large
+  6    rate limited by pushnotifier.de
+  7    pushnotifier.de server error
+  8    --timeout exceeded
+  9    notification not delivered to any device, or to some with send --fail-on-partial
+  130  interrupted`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
@@ -47,25 +74,62 @@ You can send text and or url, or image as notifications.`,
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// Cancel any in-flight API call when the user interrupts pnctl or the process is asked to terminate.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Errors returned here come from cobra itself, e.g. unknown commands or flags.
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initLogger, initConfig)
 
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", fmt.Sprintf("config file (default is %v)", config.GetConfigFilePath()))
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "show debug output, including device IDs")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only show errors")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "maximum time to wait for the pushnotifier.de API, e.g. 30s (default is no timeout)")
+	rootCmd.PersistentFlags().String("base-url", "", fmt.Sprintf("base URL of the API, e.g. of a pnctl mock-server (default is %v)", pushnotifier.DefaultBaseURL))
+	cobra.CheckErr(viper.BindPFlag(baseURLKey, rootCmd.PersistentFlags().Lookup("base-url")))
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
 	// rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
+// commandContext returns the context API calls made by cmd should use. It is
+// cancelled on SIGINT/SIGTERM and, if --timeout is given, once it elapses.
+func commandContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// initLogger sets up logger according to the --verbose and --quiet flags.
+func initLogger() {
+	level := pushnotifier.LevelInfo
+	switch {
+	case quiet:
+		level = pushnotifier.LevelError
+	case verbose:
+		level = pushnotifier.LevelDebug
+	}
+
+	logger = pushnotifier.NewStdLogger(log.New(os.Stderr, "", log.LstdFlags), level)
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
@@ -82,10 +146,13 @@ func initConfig() {
 		viper.SetConfigName("pushnotifier")
 	}
 
+	// The config file holds API credentials, so never create it readable by others.
+	viper.SetConfigPermissions(0600)
+
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+		logger.Info("Using config file", "path", viper.ConfigFileUsed())
 	}
 }
@@ -23,11 +23,14 @@ package cmd
 
 import (
 	"errors"
-	"log"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
 
 	"github.com/mavjs/pushnotifier"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // sendCmd represents the send command
@@ -36,83 +39,233 @@ var sendCmd = &cobra.Command{
 	Short: "Sends different types of content to registered devices.",
 	Long: `This commands allow you to send text, URL or both, image to your registered devices via pushnotifier.de
 To send a text notification, you can invoke the command as: send "my text notification"
+To send an image generated by another program, pipe it to: send --image -
+To send the last lines of a log as an image, pipe them to: send --render-text -
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 
 		if len(args) > 1 {
-			cobra.CheckErr("Too many arguments. Provide only 1 argument to send as text content.")
+			checkErr(usageError{"too many arguments. provide only 1 argument to send as text content"})
 		}
 
 		textContent := cmd.Flags().Arg(0)
 
 		notifySend, err := cmd.Flags().GetBool("notify")
 		if err != nil {
-			cobra.CheckErr(err)
+			checkErr(err)
 		}
 
 		devices, err := cmd.Flags().GetStringSlice("devices")
 		if err != nil {
-			cobra.CheckErr(err)
+			checkErr(err)
 		}
+		devices = loadDeviceBook().resolve(devices)
 
 		urlContent, err := cmd.Flags().GetString("url")
 		if err != nil {
-			cobra.CheckErr(err)
+			checkErr(err)
 		}
 
 		imagePath, err := cmd.Flags().GetString("image")
 		if err != nil {
-			cobra.CheckErr(err)
+			checkErr(err)
+		}
+
+		imageName, err := cmd.Flags().GetString("image-name")
+		if err != nil {
+			checkErr(err)
+		}
+
+		fitImage, err := cmd.Flags().GetBool("fit")
+		if err != nil {
+			checkErr(err)
+		}
+
+		renderText, err := cmd.Flags().GetBool("render-text")
+		if err != nil {
+			checkErr(err)
+		}
+
+		renderLines, err := cmd.Flags().GetInt("render-lines")
+		if err != nil {
+			checkErr(err)
+		}
+
+		overflow, err := cmd.Flags().GetString("overflow")
+		if err != nil {
+			checkErr(err)
 		}
 
 		silentSend, err := cmd.Flags().GetBool("silent")
 		if err != nil {
-			cobra.CheckErr(err)
+			checkErr(err)
+		}
+
+		failOnPartial, err := cmd.Flags().GetBool("fail-on-partial")
+		if err != nil {
+			checkErr(err)
+		}
+
+		queue, err := cmd.Flags().GetBool("queue")
+		if err != nil {
+			checkErr(err)
+		}
+
+		queueOnFailure, err := queueOnFailureEnabled(cmd)
+		if err != nil {
+			checkErr(err)
 		}
 
-		packageName := viper.GetString("PACKAGE_NAME")
-		apiToken := viper.GetString("API_TOKEN")
-		appToken := viper.GetString("APP_TOKEN")
+		retries, err := cmd.Flags().GetInt("retries")
+		if err != nil {
+			checkErr(err)
+		}
+
+		retryMaxWait, err := cmd.Flags().GetDuration("retry-max-wait")
+		if err != nil {
+			checkErr(err)
+		}
 
-		if packageName == "" || apiToken == "" {
-			cobra.CheckErr(errors.New("no package name or api token can be found. please use `register` command to register"))
+		if notifySend && textContent == "" && urlContent == "" {
+			checkErr(usageError{"notify send option was selected however text and or url content not provided"})
 		}
-		pn := pushnotifier.NewClient(nil, packageName, apiToken, appToken)
 
-		if notifySend {
-			if textContent == "" && urlContent == "" {
-				cobra.CheckErr("notify send option was selected however text and or url content not provided")
+		var notifications []pushnotifier.Notification
+
+		if renderText {
+			if textContent == "-" {
+				if imagePath == "-" {
+					checkErr(usageError{"text to render and image cannot both be read from stdin"})
+				}
+
+				stdin, err := io.ReadAll(os.Stdin)
+				checkErr(err)
+				textContent = string(stdin)
 			}
 
-			log.Println("Sending notification with both text and url")
-			if err := pn.SendNotification(textContent, urlContent, devices, silentSend); err != nil {
-				cobra.CheckErr(err)
+			rendered, err := pushnotifier.RenderText(textContent, pushnotifier.RenderOptions{MaxLines: renderLines})
+			if err != nil {
+				checkErr(usageError{err.Error()})
 			}
+
+			notifications = append(notifications, pushnotifier.Notification{Kind: pushnotifier.KindImage, Image: &pushnotifier.Image{Data: rendered, Name: "text.png"}})
+			textContent = ""
 		}
 
-		if textContent != "" && urlContent == "" {
-			log.Println("Sending text notification")
-			if err := pn.SendText(textContent, devices, silentSend); err != nil {
-				cobra.CheckErr(err)
+		// A text and a URL are sent together as one notification, which opens the URL when tapped.
+		if textContent != "" || urlContent != "" {
+			notifications = append(notifications, pushnotifier.Notification{Text: textContent, URL: urlContent})
+		}
+
+		if imagePath != "" {
+			image := &pushnotifier.Image{Path: imagePath, Name: imageName, Fit: fitImage}
+			if imagePath == "-" {
+				image = &pushnotifier.Image{Reader: os.Stdin, Name: imageName, Fit: fitImage}
+				if imageName == "" {
+					image.Name = "image"
+				}
 			}
+			notifications = append(notifications, pushnotifier.Notification{Kind: pushnotifier.KindImage, Image: image})
+		}
+
+		if len(notifications) == 0 {
+			checkErr(usageError{"nothing to send. provide text, --url or --image content"})
+		}
+
+		policy, err := parseOverflowPolicy(overflow)
+		checkErr(err)
+
+		opts := []pushnotifier.Option{pushnotifier.WithOverflowPolicy(policy)}
+
+		if retries > 0 {
+			policy := pushnotifier.DefaultRetryPolicy()
+			policy.MaxAttempts = retries + 1
+			policy.MaxBackoff = retryMaxWait
+			opts = append(opts, pushnotifier.WithRetryPolicy(policy))
+		}
+
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		var outbox *pushnotifier.Outbox
+		if queue || queueOnFailure {
+			outbox, err = openOutbox()
+			checkErr(err)
 		}
 
-		if textContent == "" && urlContent != "" {
-			log.Println("Sending URL notification")
-			if err := pn.SendURL(urlContent, devices, silentSend); err != nil {
-				cobra.CheckErr(err)
+		if queue {
+			for _, notification := range notifications {
+				notification.Devices = devices
+				notification.Silent = silentSend
+				notification.Overflow = policy
+
+				id, duplicate, err := outbox.Enqueue(ctx, notification)
+				checkErr(err)
+
+				if duplicate {
+					logger.Info("Notification already queued or recently sent, skipping", "id", id)
+					continue
+				}
+				fmt.Printf("queued\t%v\n", id)
 			}
+			return
 		}
 
-		if imagePath != "" {
-			log.Println("Sending image notification")
-			if err := pn.SendImage(imagePath, devices, silentSend); err != nil {
-				cobra.CheckErr(err)
+		pn, err := newClient(opts...)
+		checkErr(err)
+
+		for _, notification := range notifications {
+			notification.Devices = devices
+			notification.Silent = silentSend
+
+			logger.Info("Sending notification")
+			if outbox == nil {
+				result, err := pn.Send(ctx, notification)
+				reportSendResult(result, err, failOnPartial)
+				continue
+			}
+
+			result, err := outbox.Send(ctx, pn, notification)
+			if errors.Is(err, pushnotifier.ErrQueued) {
+				logger.Info("Run `pnctl queue flush` to send queued notifications")
+				continue
 			}
+			reportSendResult(result, err, failOnPartial)
 		}
 	},
 }
 
+// parseOverflowPolicy parses the value of an --overflow flag.
+func parseOverflowPolicy(overflow string) (pushnotifier.OverflowPolicy, error) {
+	policy := pushnotifier.OverflowPolicy(overflow)
+	switch policy {
+	case pushnotifier.OverflowError, pushnotifier.OverflowTruncate, pushnotifier.OverflowSplit:
+		return policy, nil
+	}
+	return "", usageError{fmt.Sprintf("invalid --overflow %q. use error, truncate or split", overflow)}
+}
+
+// reportSendResult prints the devices a notification was and was not delivered
+// to, and exits if sending failed or, with failOnPartial, if any device failed.
+func reportSendResult(result *pushnotifier.SendResult, err error, failOnPartial bool) {
+	if result != nil {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, id := range result.Delivered {
+			fmt.Fprintf(w, "delivered\t%v\t\n", id)
+		}
+		for _, failure := range result.Failed {
+			fmt.Fprintf(w, "failed\t%v\t%v\n", failure.DeviceID, failure.Reason)
+		}
+		w.Flush()
+	}
+
+	checkErr(err)
+
+	if failOnPartial && result.Partial() {
+		checkErr(errPartialDelivery)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(sendCmd)
 
@@ -129,10 +282,25 @@ func init() {
 	sendCmd.Flags().BoolP("notify", "n", false, "Option to send a notification that contains both text and url. User is taken to URL when tapping notification")
 
 	sendCmd.Flags().StringP("url", "u", "", "The URL to include during notification send")
-	sendCmd.Flags().StringP("image", "i", "", "The path to an iamge to send as notification")
+	sendCmd.Flags().StringP("image", "i", "", "The path to an iamge to send as notification, or - to read it from stdin")
+	sendCmd.Flags().String("image-name", "", "The file name to send the image with (default is the base name of the path, or image for stdin). The extension of the image type is added if it has none")
+	sendCmd.Flags().Bool("fit", false, "Re-encode and downscale a PNG, JPEG or GIF image larger than 5 MB until it fits, instead of failing")
+
+	sendCmd.Flags().StringSliceP("devices", "d", make([]string, 0), "List of device IDs, aliases or groups to send notification")
 
-	sendCmd.Flags().StringSliceP("devices", "d", make([]string, 0), "List of device IDs to send notification")
+	sendCmd.Flags().Bool("render-text", false, "Send the text rendered as an image, e.g. for log excerpts too long for a text notification. Use - as text to read it from stdin")
+	sendCmd.Flags().Int("render-lines", 200, "Number of lines, from the end of the text, rendered by --render-text")
+
+	sendCmd.Flags().String("overflow", "error", "What to do with a text longer than 2000 characters: error, truncate it with an ellipsis, or split it into numbered notifications")
 
 	sendCmd.Flags().BoolP("silent", "s", false, "Option to send notification in silent mode")
 
+	sendCmd.Flags().Int("retries", 0, "Number of times to retry sending after a transient failure, e.g. a 503 or connection error")
+	sendCmd.Flags().Duration("retry-max-wait", 30*time.Second, "Maximum time to wait between two retries")
+
+	sendCmd.Flags().Bool("queue", false, "Queue the notification in the outbox instead of sending it, see the queue command")
+	sendCmd.Flags().Bool("queue-on-failure", false, "Queue the notification in the outbox if it cannot be sent, e.g. while the network is down, instead of failing")
+
+	sendCmd.Flags().Bool("fail-on-partial", false, "Exit with a non-zero code if the notification was not delivered to every device")
+
 }
@@ -0,0 +1,377 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mavjs/pushnotifier"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	// serveTokensKey is the config key mapping the names of relay callers to the shared secrets they authenticate with.
+	serveTokensKey = "SERVE_TOKENS"
+	// relayMaxRequestSize is the largest request body the relay accepts, enough
+	// for an image somewhat larger than 5 MB, base64 encoded, to be sent with fit.
+	relayMaxRequestSize = 10 << 20
+	// serveRefreshJitter is the maximum random time the relay refreshes its App Token earlier than needed.
+	serveRefreshJitter = 5 * time.Minute
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run an HTTP relay sending notifications for other hosts",
+	Long: `Run a local HTTP API that sends notifications with the credentials of the config file, so that other hosts and containers need none.
+Callers authenticate with a shared secret from the serve_tokens setting, which maps caller names to secrets:
+
+  serve_tokens:
+    ci: 9f2c...
+    backup: 41d7...
+
+Notifications are sent by POSTing JSON to /v1/notify, with devices given as IDs, aliases or groups, and an image base64 encoded:
+
+  curl -H "Authorization: Bearer $TOKEN" -d '{"text": "backup finished", "devices": ["oncall"]}' http://127.0.0.1:8086/v1/notify
+
+The JSON object has the fields text, url, image, image_name, fit, devices and silent. The response lists the devices the notification
+was delivered to and failed for. With --queue-on-failure, a notification that cannot be sent right away is queued in the outbox and
+answered with 202 Accepted. The App Token is refreshed whenever it is about to expire, as with token refresh --daemon, and
+/healthz reports whether the relay has a valid App Token, without authentication.
+
+Shared secrets are sent with every request, so a relay reached by other hosts must serve HTTPS, with --tls-cert and --tls-key,
+or run behind a TLS-terminating proxy. Without TLS, it should listen on a loopback address only:
+
+  pnctl serve --listen 0.0.0.0:8086 --tls-cert /etc/pnctl/relay.crt --tls-key /etc/pnctl/relay.key`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		listen, err := cmd.Flags().GetString("listen")
+		if err != nil {
+			checkErr(err)
+		}
+
+		queueOnFailure, err := queueOnFailureEnabled(cmd)
+		if err != nil {
+			checkErr(err)
+		}
+
+		tlsCert, err := cmd.Flags().GetString("tls-cert")
+		if err != nil {
+			checkErr(err)
+		}
+
+		tlsKey, err := cmd.Flags().GetString("tls-key")
+		if err != nil {
+			checkErr(err)
+		}
+
+		if (tlsCert == "") != (tlsKey == "") {
+			checkErr(usageError{"--tls-cert and --tls-key must be given together"})
+		}
+
+		tokens := viper.GetStringMapString(serveTokensKey)
+		if len(tokens) == 0 {
+			checkErr(usageError{"no shared secrets configured. add callers and their secrets to the serve_tokens setting"})
+		}
+		for caller, token := range tokens {
+			if len(token) < 16 {
+				checkErr(usageError{fmt.Sprintf("shared secret of caller %q is too short. use at least 16 characters", caller)})
+			}
+		}
+
+		pn, err := newClient()
+		checkErr(err)
+
+		// Settings are read once, as viper is not safe for concurrent use by the handlers.
+		relay := &notifyRelay{client: pn, tokens: tokens, devices: loadDeviceBook()}
+		if queueOnFailure {
+			relay.outbox, err = openOutbox()
+			checkErr(err)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/v1/notify", relay)
+		mux.HandleFunc("/healthz", relay.serveHealth)
+
+		listener, err := net.Listen("tcp", listen)
+		checkErr(err)
+
+		// Refresh the App Token in the background, as an idle relay would otherwise let it expire.
+		go func() {
+			if err := refreshDaemon(cmd.Context(), pn, serveRefreshJitter, 0); err != nil {
+				logger.Error("App Token refresh daemon stopped", "error", err)
+			}
+		}()
+
+		server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		scheme := "http"
+		if tlsCert != "" {
+			certificate, err := tls.LoadX509KeyPair(tlsCert, tlsKey)
+			if err != nil {
+				checkErr(usageError{fmt.Sprintf("unable to load --tls-cert and --tls-key: %v", err)})
+			}
+
+			server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+			scheme = "https"
+		} else if !isLoopback(listener.Addr()) {
+			logger.Warn("Serving plain HTTP on a non-loopback address, shared secrets are sent in cleartext. Use --tls-cert and --tls-key, or a TLS-terminating proxy", "listen", listener.Addr().String())
+		}
+		go func() {
+			<-cmd.Context().Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(ctx)
+		}()
+
+		logger.Info("Serving notification relay", "url", fmt.Sprintf("%v://%v/v1/notify", scheme, listener.Addr()), "callers", len(tokens))
+
+		if server.TLSConfig != nil {
+			err = server.ServeTLS(listener, "", "")
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			checkErr(err)
+		}
+	},
+}
+
+type (
+	// notifyRelay sends the notifications POSTed to it by authenticated callers with a single client.
+	notifyRelay struct {
+		client *pushnotifier.Client
+		// tokens maps the names of callers to their shared secrets.
+		tokens map[string]string
+		// devices resolves the aliases and groups of devices in requests.
+		devices deviceBook
+		// outbox, if set, queues notifications that cannot be sent right away.
+		outbox *pushnotifier.Outbox
+	}
+
+	// relayRequest is the JSON body of a request to /v1/notify.
+	relayRequest struct {
+		Text      string   `json:"text"`
+		URL       string   `json:"url"`
+		Image     []byte   `json:"image"`
+		ImageName string   `json:"image_name"`
+		Fit       bool     `json:"fit"`
+		Devices   []string `json:"devices"`
+		Silent    bool     `json:"silent"`
+	}
+
+	// relayResponse is the JSON body of a response from /v1/notify.
+	relayResponse struct {
+		Delivered []string       `json:"delivered,omitempty"`
+		Failed    []relayFailure `json:"failed,omitempty"`
+		Queued    bool           `json:"queued,omitempty"`
+		Error     string         `json:"error,omitempty"`
+	}
+
+	// relayFailure is a device a relayed notification could not be delivered to.
+	relayFailure struct {
+		Device string `json:"device"`
+		Reason string `json:"reason"`
+	}
+)
+
+func (relay *notifyRelay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeRelayResponse(w, http.StatusMethodNotAllowed, relayResponse{Error: "only POST is allowed"})
+		return
+	}
+
+	caller, ok := relay.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="pnctl"`)
+		writeRelayResponse(w, http.StatusUnauthorized, relayResponse{Error: "missing or invalid shared secret"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, relayMaxRequestSize+1))
+	if err != nil {
+		writeRelayResponse(w, http.StatusBadRequest, relayResponse{Error: err.Error()})
+		return
+	}
+	if len(body) > relayMaxRequestSize {
+		writeRelayResponse(w, http.StatusRequestEntityTooLarge, relayResponse{Error: fmt.Sprintf("request body is larger than %v bytes", relayMaxRequestSize)})
+		return
+	}
+
+	var req relayRequest
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeRelayResponse(w, http.StatusBadRequest, relayResponse{Error: fmt.Sprintf("invalid JSON body: %v", err)})
+		return
+	}
+
+	notification := pushnotifier.Notification{
+		Text:    req.Text,
+		URL:     req.URL,
+		Devices: relay.devices.resolve(req.Devices),
+		Silent:  req.Silent,
+	}
+	if req.Image != nil {
+		notification.Image = &pushnotifier.Image{Data: req.Image, Name: req.ImageName, Fit: req.Fit}
+		if req.ImageName == "" {
+			notification.Image.Name = "image"
+		}
+	}
+
+	ctx := r.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var result *pushnotifier.SendResult
+	if relay.outbox != nil {
+		result, err = relay.outbox.Send(ctx, relay.client, notification)
+	} else {
+		result, err = relay.client.Send(ctx, notification)
+	}
+
+	if errors.Is(err, pushnotifier.ErrQueued) {
+		logger.Warn("Relayed notification queued", "caller", caller, "error", err)
+		writeRelayResponse(w, http.StatusAccepted, relayResponse{Queued: true, Error: err.Error()})
+		return
+	}
+
+	var resp relayResponse
+	if result != nil {
+		resp.Delivered = result.Delivered
+		for _, failure := range result.Failed {
+			resp.Failed = append(resp.Failed, relayFailure{Device: failure.DeviceID, Reason: failure.Reason})
+		}
+	}
+	if err != nil {
+		// The error of a notification not delivered to any device lists the devices, whose IDs are only logged at debug level.
+		if errors.Is(err, pushnotifier.ErrDeliveryFailed) {
+			logger.Error("Notification not delivered to any device", "caller", caller, "failed", len(resp.Failed))
+			logger.Debug("Delivery failed", "caller", caller, "error", err)
+		} else {
+			logger.Error("Unable to relay notification", "caller", caller, "error", err)
+		}
+		resp.Error = err.Error()
+		writeRelayResponse(w, relayStatus(err), resp)
+		return
+	}
+
+	logger.Info("Notification relayed", "caller", caller, "delivered", len(resp.Delivered), "failed", len(resp.Failed))
+	writeRelayResponse(w, http.StatusOK, resp)
+}
+
+// authenticate returns the name of the caller whose shared secret r carries as a bearer token.
+func (relay *notifyRelay) authenticate(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := []byte(header[len(prefix):])
+
+	// Every secret is compared, so that the time taken does not tell which caller came close.
+	caller := ""
+	for name, secret := range relay.tokens {
+		if subtle.ConstantTimeCompare(token, []byte(secret)) == 1 {
+			caller = name
+		}
+	}
+	return caller, caller != ""
+}
+
+// serveHealth reports whether the relay can send notifications, i.e. has an App Token that has not expired.
+func (relay *notifyRelay) serveHealth(w http.ResponseWriter, r *http.Request) {
+	info := relay.client.TokenInfo()
+
+	status, message := http.StatusOK, "ok"
+	switch {
+	case !info.HasToken:
+		status, message = http.StatusServiceUnavailable, "no App Token. use `pnctl login` to obtain one"
+	case !info.ExpiresAt.IsZero() && info.Remaining(time.Now()) <= 0:
+		status, message = http.StatusServiceUnavailable, "App Token expired. use `pnctl login` to obtain a new one"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Status string `json:"status"`
+	}{message})
+}
+
+// isLoopback reports whether addr is a loopback address, only reachable from the local host.
+func isLoopback(addr net.Addr) bool {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// relayStatus maps an error sending a relayed notification to the HTTP status code answered to the caller.
+func relayStatus(err error) int {
+	switch {
+	case errors.Is(err, pushnotifier.ErrInvalidNotification):
+		return http.StatusBadRequest
+	case errors.Is(err, pushnotifier.ErrPayloadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, pushnotifier.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	}
+	// Anything else, including being unauthorized by pushnotifier.de, is a failure of the upstream API rather than of the caller.
+	return http.StatusBadGateway
+}
+
+// writeRelayResponse writes resp as JSON with the given status code.
+func writeRelayResponse(w http.ResponseWriter, status int, resp relayResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("listen", "127.0.0.1:8086", "Address to listen on")
+	serveCmd.Flags().String("tls-cert", "", "PEM file of the certificate to serve HTTPS with, along with --tls-key")
+	serveCmd.Flags().String("tls-key", "", "PEM file of the private key of --tls-cert")
+	serveCmd.Flags().Bool("queue-on-failure", false, "Queue notifications that cannot be sent right away in the outbox, answering 202 Accepted, see the queue command")
+}
@@ -0,0 +1,272 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mavjs/pushnotifier"
+	"github.com/mavjs/pushnotifier/pkg/config"
+	"github.com/mavjs/pushnotifier/pushnotifiertest"
+	"github.com/stretchr/testify/assert"
+)
+
+const relaySecret = "0123456789abcdef"
+
+// newTestRelay returns a relay sending to the API at baseURL with an App Token
+// issued by fake, which is saved to a config file whenever it is refreshed.
+func newTestRelay(t *testing.T, baseURL string, fake *pushnotifiertest.Fake) *notifyRelay {
+	store := config.NewFileTokenStore(filepath.Join(t.TempDir(), "pushnotifier.yaml"))
+
+	pn, err := pushnotifier.New(pushnotifiertest.DefaultPackageName, pushnotifiertest.DefaultAPIToken,
+		pushnotifier.WithBaseURL(baseURL), pushnotifier.WithTokenStore(envTokenStore{store}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appToken, expiresAt := fake.IssueToken(pushnotifiertest.DefaultUsername)
+	pn.SetToken(appToken, expiresAt.Unix())
+
+	return &notifyRelay{
+		client:  pn,
+		tokens:  map[string]string{"ci": relaySecret},
+		devices: deviceBook{aliases: map[string]string{"phone": "dev1"}, groups: map[string][]string{"all": {"phone", "dev2"}}},
+	}
+}
+
+// relayPost POSTs body to relay as the caller with the given shared secret.
+func relayPost(relay http.Handler, secret, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/v1/notify", strings.NewReader(body))
+	if secret != "" {
+		r.Header.Set("Authorization", "Bearer "+secret)
+	}
+
+	w := httptest.NewRecorder()
+	relay.ServeHTTP(w, r)
+	return w
+}
+
+// relayResult decodes the JSON body of w.
+func relayResult(w *httptest.ResponseRecorder) relayResponse {
+	var resp relayResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	return resp
+}
+
+func TestNotifyRelay(t *testing.T) {
+	assert := assert.New(t)
+
+	server := pushnotifiertest.NewServer(pushnotifiertest.Config{})
+	defer server.Close()
+
+	relay := newTestRelay(t, server.URL, server.Fake)
+
+	w := httptest.NewRecorder()
+	relay.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/notify", nil))
+	assert.Equal(http.StatusMethodNotAllowed, w.Code, "[TestNotifyRelay] Expected 405 for a GET request")
+	assert.Equal(http.MethodPost, w.Header().Get("Allow"), "[TestNotifyRelay] Expected POST to be allowed")
+
+	for _, secret := range []string{"", "fedcba9876543210", relaySecret + "0", relaySecret[:15]} {
+		w = relayPost(relay, secret, `{"text": "hello", "devices": ["dev1"]}`)
+		assert.Equal(http.StatusUnauthorized, w.Code, "[TestNotifyRelay] Expected 401 for the shared secret %q", secret)
+		assert.NotEmpty(w.Header().Get("WWW-Authenticate"), "[TestNotifyRelay] Expected a Bearer challenge")
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/notify", strings.NewReader(`{"text": "hello"}`))
+	r.SetBasicAuth("ci", relaySecret)
+	w = httptest.NewRecorder()
+	relay.ServeHTTP(w, r)
+	assert.Equal(http.StatusUnauthorized, w.Code, "[TestNotifyRelay] Expected 401 for basic auth")
+
+	w = relayPost(relay, relaySecret, `{"text": "`+strings.Repeat("a", relayMaxRequestSize)+`"}`)
+	assert.Equal(http.StatusRequestEntityTooLarge, w.Code, "[TestNotifyRelay] Expected 413 for a body larger than relayMaxRequestSize")
+
+	w = relayPost(relay, relaySecret, `{"text": "hello", "devices": ["dev1"], "priority": "high"}`)
+	assert.Equal(http.StatusBadRequest, w.Code, "[TestNotifyRelay] Expected 400 for an unknown field")
+	assert.Contains(relayResult(w).Error, "priority", "[TestNotifyRelay] Expected the error to name the unknown field")
+
+	w = relayPost(relay, relaySecret, `{"text": "hello", `)
+	assert.Equal(http.StatusBadRequest, w.Code, "[TestNotifyRelay] Expected 400 for invalid JSON")
+
+	assert.Empty(server.Notifications(), "[TestNotifyRelay] Expected rejected requests not to be sent")
+
+	r = httptest.NewRequest(http.MethodPost, "/v1/notify", strings.NewReader(`{"text": "hello", "devices": ["Phone"]}`))
+	r.Header.Set("Authorization", "bearer "+relaySecret)
+	w = httptest.NewRecorder()
+	relay.ServeHTTP(w, r)
+	assert.Equal(http.StatusOK, w.Code, "[TestNotifyRelay] Expected the notification to be relayed, with the scheme of the secret in any case")
+	assert.Equal([]string{"dev1"}, relayResult(w).Delivered, "[TestNotifyRelay] Expected the alias to be resolved")
+
+	w = relayPost(relay, relaySecret, `{"text": "hello", "devices": ["all", "dev1"]}`)
+	assert.Equal(http.StatusOK, w.Code, "[TestNotifyRelay] Expected the notification to be relayed")
+	if notifications := server.Notifications(); assert.Len(notifications, 2) {
+		assert.Equal([]string{"dev1", "dev2"}, notifications[1].Devices, "[TestNotifyRelay] Expected the group to be resolved, and each device to be sent to once")
+	}
+
+	w = relayPost(relay, relaySecret, `{"image": "iVBORw0KGgo=", "devices": ["dev2"]}`)
+	assert.Equal(http.StatusOK, w.Code, "[TestNotifyRelay] Expected the image to be relayed")
+	if notifications := server.Notifications(); assert.Len(notifications, 3) {
+		assert.Equal("image.png", notifications[2].Filename, "[TestNotifyRelay] Expected an image without a name to be named image, with the extension of its type")
+	}
+
+	// Errors of pushnotifier.de are told apart from those of the caller.
+	w = relayPost(relay, relaySecret, `{"devices": ["dev1"]}`)
+	assert.Equal(http.StatusBadRequest, w.Code, "[TestNotifyRelay] Expected 400 for a notification without text")
+
+	server.Reject("dev1")
+	w = relayPost(relay, relaySecret, `{"text": "hello", "devices": ["phone"]}`)
+	assert.Equal(http.StatusBadGateway, w.Code, "[TestNotifyRelay] Expected 502 for a notification not delivered to any device")
+	if failed := relayResult(w).Failed; assert.Len(failed, 1, "[TestNotifyRelay] Expected the failed device to be listed") {
+		assert.Equal("dev1", failed[0].Device)
+	}
+
+	server.Fail("notifications/text", http.StatusTooManyRequests, 1)
+	w = relayPost(relay, relaySecret, `{"text": "hello", "devices": ["dev2"]}`)
+	assert.Equal(http.StatusTooManyRequests, w.Code, "[TestNotifyRelay] Expected 429 when rate limited by pushnotifier.de")
+
+	server.Fail("notifications/text", http.StatusServiceUnavailable, 1)
+	w = relayPost(relay, relaySecret, `{"text": "hello", "devices": ["dev2"]}`)
+	assert.Equal(http.StatusBadGateway, w.Code, "[TestNotifyRelay] Expected 502 when pushnotifier.de fails")
+	assert.False(relayResult(w).Queued, "[TestNotifyRelay] Expected nothing to be queued without an outbox")
+
+	server.ExpireTokens()
+	w = relayPost(relay, relaySecret, `{"text": "hello", "devices": ["dev2"]}`)
+	assert.Equal(http.StatusBadGateway, w.Code, "[TestNotifyRelay] Expected 502 when the App Token is rejected by pushnotifier.de")
+}
+
+func TestNotifyRelayQueue(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	server := pushnotifiertest.NewServer(pushnotifiertest.Config{})
+	defer server.Close()
+
+	relay := newTestRelay(t, server.URL, server.Fake)
+	relay.outbox = pushnotifier.NewOutbox(filepath.Join(t.TempDir(), "outbox.journal"))
+
+	server.Fail("notifications/text", http.StatusServiceUnavailable, 1)
+	w := relayPost(relay, relaySecret, `{"text": "backup finished", "devices": ["phone"]}`)
+	assert.Equal(http.StatusAccepted, w.Code, "[TestNotifyRelayQueue] Expected 202 for a notification queued after a server error")
+	assert.True(relayResult(w).Queued, "[TestNotifyRelayQueue] Expected the response to tell the notification was queued")
+
+	queued, err := relay.outbox.List(ctx)
+	assert.NoError(err)
+	if assert.Len(queued, 1, "[TestNotifyRelayQueue] Expected the notification to be queued") {
+		assert.Equal([]string{"dev1"}, queued[0].Notification.Devices, "[TestNotifyRelayQueue] Expected the resolved devices to be queued")
+	}
+
+	// Notifications the caller got wrong are not queued, as sending them later fails all the same.
+	w = relayPost(relay, relaySecret, `{"devices": ["dev1"]}`)
+	assert.Equal(http.StatusBadRequest, w.Code, "[TestNotifyRelayQueue] Expected 400 for an invalid notification")
+
+	result, err := relay.outbox.Flush(ctx, relay.client)
+	assert.NoError(err)
+	assert.Equal(pushnotifier.FlushResult{Sent: 1}, result, "[TestNotifyRelayQueue] Expected the queued notification to be sent")
+}
+
+func TestRelayStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	statuses := map[error]int{
+		pushnotifier.ErrInvalidNotification: http.StatusBadRequest,
+		pushnotifier.ErrPayloadTooLarge:     http.StatusRequestEntityTooLarge,
+		pushnotifier.ErrRateLimited:         http.StatusTooManyRequests,
+		context.DeadlineExceeded:            http.StatusGatewayTimeout,
+		pushnotifier.ErrDeliveryFailed:      http.StatusBadGateway,
+		pushnotifier.ErrUnauthorized:        http.StatusBadGateway,
+		pushnotifier.ErrNotFound:            http.StatusBadGateway,
+		errors.New("connection refused"):    http.StatusBadGateway,
+	}
+	for err, status := range statuses {
+		assert.Equal(status, relayStatus(fmt.Errorf("[Send] %w", err)), "[TestRelayStatus] Expected %v for %v", status, err)
+	}
+}
+
+func TestNotifyRelayConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	fake := pushnotifiertest.NewFake(pushnotifiertest.Config{})
+	// Unlike the fake, keep refreshed App Tokens valid, so that requests sent with one while it is refreshed do not fail.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/user/refresh") {
+			appToken, expiresAt := fake.IssueToken(pushnotifiertest.DefaultUsername)
+			json.NewEncoder(w).Encode(map[string]interface{}{"app_token": appToken, "expires_at": expiresAt.Unix()})
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	relay := newTestRelay(t, server.URL+"/v2/", fake)
+	appToken, _ := relay.client.Token()
+
+	// Refresh the App Token, and so save it, over and over while requests are handled, as the refresh daemon of pnctl serve would.
+	done := make(chan struct{})
+	refreshed := make(chan int)
+	go func() {
+		refreshes := 0
+		for {
+			select {
+			case <-done:
+				refreshed <- refreshes
+				return
+			default:
+			}
+			if err := relay.client.RefreshTokenContext(context.Background()); err == nil {
+				refreshes++
+			}
+		}
+	}()
+
+	codes := make([]int, 50)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := i; j < len(codes); j += 5 {
+				codes[j] = relayPost(relay, relaySecret, `{"text": "backup finished", "devices": ["all"]}`).Code
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(done)
+
+	for i, code := range codes {
+		assert.Equal(http.StatusOK, code, "[TestNotifyRelayConcurrent] Expected request %v to be relayed", i)
+	}
+	assert.Len(fake.Notifications(), len(codes), "[TestNotifyRelayConcurrent] Expected every notification to be sent")
+	assert.True(<-refreshed > 0, "[TestNotifyRelayConcurrent] Expected the App Token to be refreshed while requests were handled")
+
+	latest, _ := relay.client.Token()
+	assert.NotEqual(appToken, latest, "[TestNotifyRelayConcurrent] Expected the refreshed App Token to be used")
+}
@@ -0,0 +1,266 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/mavjs/pushnotifier"
+	"github.com/spf13/cobra"
+)
+
+// tokenCmd represents the token command
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Inspect and manage the App Token",
+	Long:  `Inspect and manage the App Token stored in the config file, which identifies your requests on behalf of a user.`,
+}
+
+// tokenStatusCmd represents the token status command
+var tokenStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the expiry of the App Token",
+	Long:  `Show which user the stored App Token belongs to, when it expires and for how long it is still valid.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pn, err := newClient()
+		checkErr(err)
+
+		checkErr(printTokenStatus(os.Stdout, pn.TokenInfo(), loggedInUser()))
+	},
+}
+
+// tokenRefreshCmd represents the token refresh command
+var tokenRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Refresh the App Token and store it",
+	Long: `Refresh the App Token now and store the new token and its expiry in the config file.
+
+With --daemon, pnctl keeps running and refreshes the App Token every time it is about to expire, which keeps the token valid on hosts that rarely send notifications.
+Refreshes are scheduled at a random point within --jitter before the token enters its refresh window, so that several hosts sharing an account do not refresh at once.
+Failed refreshes are reported and retried with increasing backoff, and after --max-failures consecutive failures pnctl exits.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		daemon, err := cmd.Flags().GetBool("daemon")
+		if err != nil {
+			checkErr(err)
+		}
+
+		jitter, err := cmd.Flags().GetDuration("jitter")
+		if err != nil {
+			checkErr(err)
+		}
+
+		maxFailures, err := cmd.Flags().GetInt("max-failures")
+		if err != nil {
+			checkErr(err)
+		}
+
+		pn, err := newClient()
+		checkErr(err)
+
+		if !daemon {
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
+
+			checkErr(pn.RefreshTokenContext(ctx))
+			checkErr(printTokenStatus(os.Stdout, pn.TokenInfo(), loggedInUser()))
+			return
+		}
+
+		checkErr(refreshDaemon(cmd.Context(), pn, jitter, maxFailures))
+	},
+}
+
+const (
+	// daemonMinBackoff and daemonMaxBackoff bound the wait before retrying a failed refresh in daemon mode.
+	// daemonMinBackoff is also waited at least after a successful refresh, in
+	// case the server returns a token that is already due to be refreshed.
+	daemonMinBackoff = 30 * time.Second
+	daemonMaxBackoff = 10 * time.Minute
+)
+
+// refreshDaemon refreshes the App Token of pn whenever it is about to expire,
+// until ctx is done or maxFailures consecutive refreshes failed.
+func refreshDaemon(ctx context.Context, pn *pushnotifier.Client, jitter time.Duration, maxFailures int) error {
+	failures := 0
+	backoff := daemonMinBackoff
+	minWait := time.Duration(0)
+
+	for {
+		wait := nextRefresh(pn.TokenInfo(), time.Now(), jitter, minWait)
+		if failures > 0 {
+			wait = backoff
+		}
+
+		logger.Info("Waiting to refresh App Token", "in", formatDuration(wait), "at", time.Now().Add(wait).Format(time.RFC3339))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Info("Stopping App Token refresh daemon")
+			return nil
+		case <-timer.C:
+		}
+
+		// --timeout applies to each refresh rather than to the daemon as a whole.
+		var (
+			reqCtx context.Context
+			cancel context.CancelFunc
+		)
+		if timeout > 0 {
+			reqCtx, cancel = context.WithTimeout(ctx, timeout)
+		} else {
+			reqCtx, cancel = context.WithCancel(ctx)
+		}
+		err := pn.RefreshTokenContext(reqCtx)
+		cancel()
+
+		if err == nil {
+			failures = 0
+			backoff = daemonMinBackoff
+			minWait = daemonMinBackoff
+			logger.Info("App Token refreshed", "expires_at", pn.TokenInfo().ExpiresAt.Format(time.RFC3339))
+			continue
+		}
+
+		if ctx.Err() != nil {
+			logger.Info("Stopping App Token refresh daemon")
+			return nil
+		}
+
+		failures++
+		logger.Error("Unable to refresh App Token", "attempt", failures, "retry_in", formatDuration(backoff), "error", err)
+
+		if maxFailures > 0 && failures >= maxFailures {
+			return fmt.Errorf("giving up after %d consecutive failed refreshes: %w", failures, err)
+		}
+
+		if failures > 1 {
+			backoff *= 2
+			if backoff > daemonMaxBackoff {
+				backoff = daemonMaxBackoff
+			}
+		}
+	}
+}
+
+// nextRefresh returns how long to wait before refreshing the App Token described
+// by info: until a random point within jitter before it enters its refresh window,
+// but at least minWait. A token with unknown expiry is refreshed after minWait, which tells its expiry.
+func nextRefresh(info pushnotifier.TokenInfo, now time.Time, jitter, minWait time.Duration) time.Duration {
+	refreshAt := info.RefreshAt()
+	if refreshAt.IsZero() {
+		return minWait
+	}
+
+	if jitter > 0 {
+		refreshAt = refreshAt.Add(-time.Duration(rand.Int63n(int64(jitter))))
+	}
+
+	if wait := refreshAt.Sub(now); wait > minWait {
+		return wait
+	}
+	return minWait
+}
+
+// printTokenStatus writes the user and expiry of the App Token described by
+// info to w, and warns on stderr if the token is about to expire. userName is
+// shown if info does not hold the name of the user.
+func printTokenStatus(w io.Writer, info pushnotifier.TokenInfo, userName string) error {
+	if !info.HasToken {
+		return errors.New("no App Token can be found. please use `login` command to log in")
+	}
+
+	if info.UserName != "" {
+		userName = info.UserName
+	}
+	if userName == "" {
+		userName = "unknown"
+	}
+
+	fmt.Fprintf(w, "Username:   %v\n", userName)
+	if info.Avatar != "" {
+		fmt.Fprintf(w, "Avatar:     %v\n", info.Avatar)
+	}
+
+	if info.ExpiresAt.IsZero() {
+		fmt.Fprintln(w, "Expires at: unknown, the App Token is never refreshed")
+		return nil
+	}
+
+	now := time.Now()
+	remaining := info.Remaining(now)
+
+	fmt.Fprintf(w, "Expires at: %v\n", info.ExpiresAt.Local().Format(time.RFC1123))
+	fmt.Fprintf(w, "Remaining:  %v\n", formatDuration(remaining))
+
+	switch {
+	case remaining <= 0:
+		fmt.Fprintln(os.Stderr, "Warning: the App Token has expired. please use `login` command to log in again")
+	case info.InRefreshWindow(now):
+		fmt.Fprintf(os.Stderr, "Warning: the App Token expires within %v and will be refreshed by the next request\n", formatDuration(pushnotifier.RefreshWindow))
+	}
+
+	return nil
+}
+
+// formatDuration formats d in days, hours, minutes and seconds, e.g. "29d 23h 59m 59s".
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	d = d.Round(time.Second)
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	d -= minutes * time.Minute
+	seconds := d / time.Second
+
+	if days > 0 {
+		return fmt.Sprintf("%v%dd %dh %dm %ds", sign, days, hours, minutes, seconds)
+	}
+	if hours > 0 {
+		return fmt.Sprintf("%v%dh %dm %ds", sign, hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%v%dm %ds", sign, minutes, seconds)
+}
+
+func init() {
+	rootCmd.AddCommand(tokenCmd)
+	tokenCmd.AddCommand(tokenStatusCmd)
+	tokenCmd.AddCommand(tokenRefreshCmd)
+
+	tokenRefreshCmd.Flags().Bool("daemon", false, "Keep running and refresh the App Token every time it is about to expire")
+	tokenRefreshCmd.Flags().Duration("jitter", 5*time.Minute, "Maximum random time to refresh earlier than needed in daemon mode")
+	tokenRefreshCmd.Flags().Int("max-failures", 0, "Exit after this many consecutive failed refreshes in daemon mode (default is to never give up)")
+}
@@ -0,0 +1,55 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mavjs/pushnotifier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextRefresh(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+
+	unknown := pushnotifier.TokenInfo{HasToken: true}
+	assert.Equal(time.Duration(0), nextRefresh(unknown, now, 0, 0), "[TestNextRefresh] Expected a token with unknown expiry to be refreshed right away")
+	assert.Equal(daemonMinBackoff, nextRefresh(unknown, now, 0, daemonMinBackoff), "[TestNextRefresh] Expected a token with unknown expiry to be refreshed after the minimum wait")
+
+	valid := pushnotifier.TokenInfo{HasToken: true, ExpiresAt: now.Add(pushnotifier.RefreshWindow + time.Hour)}
+	assert.Equal(time.Hour, nextRefresh(valid, now, 0, daemonMinBackoff), "[TestNextRefresh] Expected the token to be refreshed when it enters its refresh window")
+
+	for i := 0; i < 100; i++ {
+		wait := nextRefresh(valid, now, 10*time.Minute, daemonMinBackoff)
+		assert.True(wait > 50*time.Minute && wait <= time.Hour, "[TestNextRefresh] Expected the refresh to be at most the jitter earlier, got %v", wait)
+	}
+
+	// A token the server returned already due to be refreshed must not be refreshed in a tight loop.
+	for _, expiresAt := range []time.Time{now, now.Add(-time.Hour), now.Add(pushnotifier.RefreshWindow)} {
+		due := pushnotifier.TokenInfo{HasToken: true, ExpiresAt: expiresAt}
+		assert.Equal(daemonMinBackoff, nextRefresh(due, now, 5*time.Minute, daemonMinBackoff), "[TestNextRefresh] Expected a token due to be refreshed to wait the minimum after a refresh")
+		assert.Equal(time.Duration(0), nextRefresh(due, now, 5*time.Minute, 0), "[TestNextRefresh] Expected a token due to be refreshed to be refreshed right away on start")
+	}
+}
@@ -22,38 +22,39 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"errors"
-	"fmt"
+	"os"
 
-	"github.com/mavjs/pushnotifier"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
-// getdevicesCmd represents the getdevices command
-var getdevicesCmd = &cobra.Command{
-	Use:   "getdevices",
-	Short: "Get connected devices",
-	Long:  `Get connected devices to your account and show them for convenience and or later used for sending notifications.`,
+// whoamiCmd represents the whoami command
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the user the stored credentials belong to",
+	Long: `Show the user the stored App Token belongs to, and when the token expires.
+By default only the config file is read. With --refresh the App Token is refreshed, which confirms the user with pushnotifier.de.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		packageName := viper.GetString("PACKAGE_NAME")
-		apiToken := viper.GetString("API_TOKEN")
-		appToken := viper.GetString("APP_TOKEN")
-
-		if packageName == "" || apiToken == "" {
-			cobra.CheckErr(errors.New("no package name or api token can be found. please use `register` command to register"))
+		refresh, err := cmd.Flags().GetBool("refresh")
+		if err != nil {
+			checkErr(err)
 		}
 
-		pn := pushnotifier.NewClient(nil, packageName, apiToken, appToken)
+		pn, err := newClient()
+		checkErr(err)
 
-		pn.GetDevices()
+		if refresh {
+			ctx, cancel := commandContext(cmd)
+			defer cancel()
 
-		for _, device := range pn.Devices {
-			fmt.Println(device)
+			checkErr(pn.RefreshTokenContext(ctx))
 		}
+
+		checkErr(printTokenStatus(os.Stdout, pn.TokenInfo(), loggedInUser()))
 	},
 }
 
 func init() {
-	rootCmd.AddCommand(getdevicesCmd)
+	rootCmd.AddCommand(whoamiCmd)
+
+	whoamiCmd.Flags().Bool("refresh", false, "Refresh the App Token to confirm the user with pushnotifier.de")
 }
@@ -22,10 +22,16 @@ THE SOFTWARE.
 package config
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
 )
 
 const (
@@ -70,3 +76,61 @@ func WriteToConfigFile(content []byte) error {
 
 	return nil
 }
+
+// UpdateConfigFile applies update to the settings in the config file at path,
+// creating the file if it does not exist. Keys in settings are lower case, as
+// viper treats them case-insensitively. The file is replaced atomically while
+// holding a lock file, so that concurrent pnctl invocations neither lose each
+// other's updates nor leave a partially written config file behind.
+func UpdateConfigFile(path string, update func(settings map[string]interface{}) error) error {
+	release, err := AcquireLock(context.Background(), path+".lock")
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	v, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+
+	settings := v.AllSettings()
+	if err := update(settings); err != nil {
+		return err
+	}
+
+	// Write from a fresh instance, as keys deleted from a nested map in settings
+	// would otherwise still be found in the config read by v.
+	out := viper.New()
+	if err := out.MergeConfigMap(settings); err != nil {
+		return err
+	}
+
+	return writeConfigAtomic(out, path)
+}
+
+// readConfigFile reads the config file at path into a new viper instance. A missing file results in an empty config.
+func readConfigFile(path string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return v, nil
+}
+
+// writeConfigAtomic writes the config held by v to a temporary file next to
+// path, and then renames it to path.
+func writeConfigAtomic(v *viper.Viper, path string) error {
+	ext := filepath.Ext(path)
+	tmpPath := strings.TrimSuffix(path, ext) + ".tmp" + ext
+
+	v.SetConfigPermissions(0600)
+	if err := v.WriteConfigAs(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package config
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// lockRetryInterval is how often acquiring a held lock file is retried.
+	lockRetryInterval = 10 * time.Millisecond
+	// staleLockAge is the age after which a lock file is assumed to be left behind by a crashed process.
+	staleLockAge = 10 * time.Second
+	// lockTouchInterval is how often a held lock file is touched, so that it never gets stale while held.
+	lockTouchInterval = staleLockAge / 4
+)
+
+// AcquireLock creates the lock file at path, waiting until it is released by
+// any other process holding it or ctx is done. The returned function releases the lock.
+//
+// Lock files are created exclusively instead of relying on flock(2), so that
+// locking works the same on every platform. A held lock file is touched
+// regularly, so that it is only found stale if its holder crashed, and it holds
+// a token unique to its holder, so that releasing it never removes a lock file
+// of another process.
+func AcquireLock(ctx context.Context, path string) (func(), error) {
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		lockFile, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = lockFile.WriteString(token)
+			if closeErr := lockFile.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return holdLock(path, token), nil
+		}
+
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			breakStaleLock(path, info)
+			continue
+		}
+
+		timer := time.NewTimer(lockRetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// newLockToken returns a token identifying the holder of a lock file.
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v %v\n", os.Getpid(), hex.EncodeToString(b)), nil
+}
+
+// holdLock touches the lock file at path, which holds token, every
+// lockTouchInterval until the returned function is called, which then removes it.
+func holdLock(path, token string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(lockTouchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				touchLock(path, token)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+
+			if ownsLock(path, token) {
+				os.Remove(path)
+			}
+		})
+	}
+}
+
+// touchLock sets the modification time of the lock file at path to now, if it still holds token.
+func touchLock(path, token string) {
+	if ownsLock(path, token) {
+		now := time.Now()
+		os.Chtimes(path, now, now)
+	}
+}
+
+// ownsLock reports whether the lock file at path holds token, i.e. was not
+// broken as stale and acquired by another process since token was written to it.
+func ownsLock(path, token string) bool {
+	content, err := os.ReadFile(path)
+	return err == nil && string(content) == token
+}
+
+// breakStaleLock removes the lock file at path, which was found stale as described
+// by stale. Removing it by path could remove a lock acquired in the meantime by a
+// process that broke the stale lock first, so it is renamed to a unique name
+// instead, and only removed if it still is the stale lock file. Otherwise it is put back.
+func breakStaleLock(path string, stale fs.FileInfo) {
+	brokenPath := fmt.Sprintf("%v.stale.%v.%v", path, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(path, brokenPath); err != nil {
+		return
+	}
+
+	if info, err := os.Stat(brokenPath); err == nil && os.SameFile(info, stale) && info.ModTime().Equal(stale.ModTime()) {
+		os.Remove(brokenPath)
+		return
+	}
+
+	// Linking fails rather than replacing a lock file acquired by yet another process.
+	os.Link(brokenPath, path)
+	os.Remove(brokenPath)
+}
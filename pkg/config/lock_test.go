@@ -0,0 +1,146 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireLock(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	release, err := AcquireLock(context.Background(), path)
+	assert.NoError(err, "[TestAcquireLock] Expected the lock to be acquired")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = AcquireLock(ctx, path)
+	assert.ErrorIs(err, context.DeadlineExceeded, "[TestAcquireLock] Expected a held lock not to be acquired")
+
+	release()
+	release, err = AcquireLock(context.Background(), path)
+	assert.NoError(err, "[TestAcquireLock] Expected a released lock to be acquired")
+	release()
+
+	_, err = os.Stat(path)
+	assert.True(os.IsNotExist(err), "[TestAcquireLock] Expected releasing the lock to remove the lock file")
+}
+
+func TestAcquireLockStale(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "test.lock")
+	old := time.Now().Add(-2 * staleLockAge)
+
+	// Of several processes finding the lock stale, only one may hold it at a time.
+	for round := 0; round < 20; round++ {
+		assert.NoError(os.WriteFile(path, nil, 0600))
+		assert.NoError(os.Chtimes(path, old, old))
+
+		var (
+			wg      sync.WaitGroup
+			holders int32
+			overlap int32
+		)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				release, err := AcquireLock(context.Background(), path)
+				if !assert.NoError(err, "[TestAcquireLockStale] Expected a stale lock to be broken") {
+					return
+				}
+				if atomic.AddInt32(&holders, 1) > 1 {
+					atomic.StoreInt32(&overlap, 1)
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&holders, -1)
+				release()
+			}()
+		}
+		wg.Wait()
+
+		assert.Zero(atomic.LoadInt32(&overlap), "[TestAcquireLockStale] Expected the lock to be held by one at a time")
+	}
+
+	// A lock acquired since it was found stale is left in place.
+	assert.NoError(os.WriteFile(path, nil, 0600))
+	assert.NoError(os.Chtimes(path, old, old))
+	stale, err := os.Stat(path)
+	assert.NoError(err)
+
+	assert.NoError(os.Remove(path))
+	assert.NoError(os.WriteFile(path, nil, 0600))
+	breakStaleLock(path, stale)
+
+	_, err = os.Stat(path)
+	assert.NoError(err, "[TestAcquireLockStale] Expected a freshly acquired lock not to be removed")
+	matches, _ := filepath.Glob(path + ".stale.*")
+	assert.Empty(matches, "[TestAcquireLockStale] Expected no renamed lock file to be left behind")
+}
+
+func TestAcquireLockOwner(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "test.lock")
+	old := time.Now().Add(-2 * staleLockAge)
+
+	release, err := AcquireLock(context.Background(), path)
+	assert.NoError(err, "[TestAcquireLockOwner] Expected the lock to be acquired")
+
+	token, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.NotEmpty(token, "[TestAcquireLockOwner] Expected the lock file to hold the token of its holder")
+
+	// A lock held for longer than staleLockAge is touched, so that it is not broken as stale.
+	assert.NoError(os.Chtimes(path, old, old))
+	touchLock(path, string(token))
+	info, err := os.Stat(path)
+	assert.NoError(err)
+	assert.True(time.Since(info.ModTime()) < staleLockAge, "[TestAcquireLockOwner] Expected a held lock file to be touched")
+
+	// A lock broken and acquired by another process in the meantime is neither touched nor removed by the former holder.
+	other := []byte("4242 0123456789abcdef\n")
+	assert.NoError(os.WriteFile(path, other, 0600))
+	assert.NoError(os.Chtimes(path, old, old))
+	touchLock(path, string(token))
+	info, err = os.Stat(path)
+	assert.NoError(err)
+	assert.True(info.ModTime().Equal(old), "[TestAcquireLockOwner] Expected the lock file of another process not to be touched")
+
+	release()
+	release()
+	content, err := os.ReadFile(path)
+	assert.NoError(err, "[TestAcquireLockOwner] Expected the lock file of another process not to be removed")
+	assert.Equal(other, content)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mavjs/pushnotifier"
+)
+
+// FileRateLimiter is a pushnotifier.Limiter that keeps its token bucket in a
+// file, so that every process using the same file shares one request budget.
+type FileRateLimiter struct {
+	// FailFast makes Wait return an error wrapping pushnotifier.ErrRateLimited instead of blocking until a request may be made.
+	FailFast bool
+
+	statePath string
+	lockPath  string
+	rate      float64
+	burst     int
+}
+
+// NewFileRateLimiter creates a FileRateLimiter keeping its state in dir, allowing
+// requestsPerSecond requests per second on average, and up to burst requests at once.
+func NewFileRateLimiter(dir string, requestsPerSecond float64, burst int) *FileRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &FileRateLimiter{
+		statePath: filepath.Join(dir, "ratelimit.json"),
+		lockPath:  filepath.Join(dir, "ratelimit.lock"),
+		rate:      requestsPerSecond,
+		burst:     burst,
+	}
+}
+
+// Wait blocks until a request may be made by this or any other process sharing the limiter's state, or ctx is done.
+func (l *FileRateLimiter) Wait(ctx context.Context) error {
+	for {
+		wait, err := l.take(ctx)
+		if err != nil {
+			return err
+		}
+
+		if wait == 0 {
+			return nil
+		}
+
+		if l.FailFast {
+			return fmt.Errorf("[FileRateLimiter] no request allowed for another %v: %w", wait.Round(time.Millisecond), pushnotifier.ErrRateLimited)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// take takes a token from the shared bucket while holding the lock file.
+func (l *FileRateLimiter) take(ctx context.Context) (time.Duration, error) {
+	release, err := AcquireLock(ctx, l.lockPath)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	bucket := pushnotifier.TokenBucket{Tokens: float64(l.burst), Last: time.Now()}
+
+	content, err := os.ReadFile(l.statePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	if err == nil {
+		// A corrupted state file is replaced by a full bucket.
+		var stored pushnotifier.TokenBucket
+		if err := json.Unmarshal(content, &stored); err == nil {
+			bucket = stored
+		}
+	}
+
+	wait := bucket.Take(l.rate, l.burst, time.Now())
+
+	content, err = json.Marshal(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	return wait, os.WriteFile(l.statePath, content, 0600)
+}
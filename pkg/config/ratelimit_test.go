@@ -0,0 +1,69 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mavjs/pushnotifier"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileRateLimiter(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	dir := t.TempDir()
+
+	// Two limiters on the same directory stand for two pnctl processes.
+	first := NewFileRateLimiter(dir, 1, 2)
+	second := NewFileRateLimiter(dir, 1, 2)
+	first.FailFast = true
+	second.FailFast = true
+
+	assert.NoError(first.Wait(ctx), "[TestFileRateLimiter] Expected the first request of the burst to be allowed")
+	assert.NoError(second.Wait(ctx), "[TestFileRateLimiter] Expected the second request of the burst to be allowed")
+	assert.ErrorIs(first.Wait(ctx), pushnotifier.ErrRateLimited, "[TestFileRateLimiter] Expected the budget to be shared by both limiters")
+	assert.ErrorIs(second.Wait(ctx), pushnotifier.ErrRateLimited, "[TestFileRateLimiter] Expected the budget to be shared by both limiters")
+
+	// Without FailFast, Wait blocks until a token is available again.
+	fast := NewFileRateLimiter(t.TempDir(), 20, 1)
+	assert.NoError(fast.Wait(ctx))
+	start := time.Now()
+	assert.NoError(fast.Wait(ctx), "[TestFileRateLimiter] Expected Wait to block until a request is allowed")
+	assert.GreaterOrEqual(time.Since(start), 40*time.Millisecond, "[TestFileRateLimiter] Expected Wait to wait for the next token")
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(NewFileRateLimiter(dir, 0.001, 1).Wait(ctx), context.DeadlineExceeded, "[TestFileRateLimiter] Expected Wait to stop when ctx is done")
+
+	// A corrupted state file is replaced by a full bucket.
+	corrupted := t.TempDir()
+	assert.NoError(os.WriteFile(filepath.Join(corrupted, "ratelimit.json"), []byte("{"), 0600))
+	limiter := NewFileRateLimiter(corrupted, 1, 1)
+	limiter.FailFast = true
+	assert.NoError(limiter.Wait(context.Background()), "[TestFileRateLimiter] Expected a corrupted state file to be replaced")
+}
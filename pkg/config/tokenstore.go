@@ -0,0 +1,136 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// AppTokenKey is the config key holding the App Token.
+	AppTokenKey = "APP_TOKEN"
+	// AppTokenExpiryKey is the config key holding the expiry of the App Token as a unix timestamp.
+	AppTokenExpiryKey = "APP_TOKEN_EXPIRY"
+	// AppTokensKey is the config key holding the App Tokens of other base URLs
+	// than pushnotifier.de's, as a list of base_url, app_token and app_token_expiry.
+	AppTokensKey = "APP_TOKENS"
+
+	baseURLField = "base_url"
+)
+
+// FileTokenStore is a pushnotifier.TokenStore keeping the App Token and its
+// expiry in a config file, next to any other settings in it. The format of the
+// file is derived from its extension, e.g. YAML for pushnotifier.yaml.
+type FileTokenStore struct {
+	// BaseURL is the base URL of the API the App Token is for, if it is not
+	// pushnotifier.de, e.g. a mock-server. Its App Token is kept under
+	// APP_TOKENS, so that it neither replaces nor is sent instead of the one
+	// for pushnotifier.de.
+	BaseURL string
+
+	path string
+}
+
+// NewFileTokenStore creates a FileTokenStore for the config file at path.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+// Load returns the App Token and its expiry stored in the config file. A token
+// without a stored expiry, e.g. one entered with `pnctl register`, is returned
+// with an expiry of -1 so that it is never refreshed.
+func (s *FileTokenStore) Load() (string, int64, error) {
+	v, err := readConfigFile(s.path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if s.BaseURL != "" {
+		entries := appTokens(v.AllSettings())
+		v = viper.New()
+		for _, entry := range entries {
+			if entry[baseURLField] == s.BaseURL {
+				if err := v.MergeConfigMap(entry); err != nil {
+					return "", 0, err
+				}
+				break
+			}
+		}
+	}
+
+	if !v.IsSet(AppTokenExpiryKey) {
+		return v.GetString(AppTokenKey), -1, nil
+	}
+	return v.GetString(AppTokenKey), v.GetInt64(AppTokenExpiryKey), nil
+}
+
+// Save stores the App Token and its expiry in the config file, see UpdateConfigFile.
+func (s *FileTokenStore) Save(appToken string, expiresAt int64) error {
+	return UpdateConfigFile(s.path, func(settings map[string]interface{}) error {
+		if s.BaseURL == "" {
+			settings[strings.ToLower(AppTokenKey)] = appToken
+			settings[strings.ToLower(AppTokenExpiryKey)] = expiresAt
+			return nil
+		}
+
+		entries := []interface{}{}
+		for _, entry := range appTokens(settings) {
+			if entry[baseURLField] != s.BaseURL {
+				entries = append(entries, entry)
+			}
+		}
+		settings[strings.ToLower(AppTokensKey)] = append(entries, map[string]interface{}{
+			baseURLField:                       s.BaseURL,
+			strings.ToLower(AppTokenKey):       appToken,
+			strings.ToLower(AppTokenExpiryKey): expiresAt,
+		})
+		return nil
+	})
+}
+
+// appTokens returns the entries of APP_TOKENS in settings, with lower case keys.
+func appTokens(settings map[string]interface{}) []map[string]interface{} {
+	list, _ := settings[strings.ToLower(AppTokensKey)].([]interface{})
+
+	entries := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		entry := make(map[string]interface{})
+		// Depending on the format of the config file, keys in lists are strings or interfaces.
+		switch item := item.(type) {
+		case map[string]interface{}:
+			for key, value := range item {
+				entry[strings.ToLower(key)] = value
+			}
+		case map[interface{}]interface{}:
+			for key, value := range item {
+				entry[strings.ToLower(fmt.Sprint(key))] = value
+			}
+		default:
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,131 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileTokenStore(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "pushnotifier.yaml")
+	assert.NoError(os.WriteFile(path, []byte("package_name: dev.myapp.pn\napp_token: ZZXX11ff\naliases:\n  phone-alice: abc123\n"), 0600))
+
+	store := NewFileTokenStore(path)
+
+	appToken, expiresAt, err := store.Load()
+	assert.NoError(err, "[TestFileTokenStore] Expected the App Token to be loaded")
+	assert.Equal("ZZXX11ff", appToken, "[TestFileTokenStore] Expected the stored App Token")
+	assert.Equal(int64(-1), expiresAt, "[TestFileTokenStore] Expected -1 for an App Token without a stored expiry")
+
+	assert.NoError(store.Save("AABB22cc", 1700000000), "[TestFileTokenStore] Expected the App Token to be saved")
+
+	appToken, expiresAt, err = store.Load()
+	assert.NoError(err, "[TestFileTokenStore] Expected the App Token to be loaded")
+	assert.Equal("AABB22cc", appToken, "[TestFileTokenStore] Expected the saved App Token")
+	assert.Equal(int64(1700000000), expiresAt, "[TestFileTokenStore] Expected the saved expiry")
+
+	v, err := readConfigFile(path)
+	assert.NoError(err)
+	assert.Equal("dev.myapp.pn", v.GetString("package_name"), "[TestFileTokenStore] Expected saving to keep other settings")
+	assert.Equal(map[string]string{"phone-alice": "abc123"}, v.GetStringMapString("aliases"), "[TestFileTokenStore] Expected saving to keep nested settings")
+
+	info, err := os.Stat(path)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm(), "[TestFileTokenStore] Expected the config file to be readable by its owner only")
+
+	// A missing config file holds no App Token, and is created by saving one.
+	missing := NewFileTokenStore(filepath.Join(t.TempDir(), "pushnotifier.yaml"))
+	appToken, expiresAt, err = missing.Load()
+	assert.NoError(err, "[TestFileTokenStore] Expected a missing config file to be no error")
+	assert.Equal("", appToken)
+	assert.Equal(int64(-1), expiresAt)
+	assert.NoError(missing.Save("AABB22cc", 1700000000), "[TestFileTokenStore] Expected saving to create the config file")
+}
+
+func TestFileTokenStoreBaseURL(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "pushnotifier.yaml")
+	assert.NoError(os.WriteFile(path, []byte("app_token: ZZXX11ff\napp_token_expiry: 1700000000\n"), 0600))
+
+	mock := NewFileTokenStore(path)
+	mock.BaseURL = "http://127.0.0.1:8085/v2/"
+
+	appToken, _, err := mock.Load()
+	assert.NoError(err)
+	assert.Equal("", appToken, "[TestFileTokenStoreBaseURL] Expected no App Token for a base URL without one, rather than the one for pushnotifier.de")
+
+	assert.NoError(mock.Save("AABB22cc", 1800000000), "[TestFileTokenStoreBaseURL] Expected the App Token to be saved")
+
+	proxy := NewFileTokenStore(path)
+	proxy.BaseURL = "https://egress.example.com/pushnotifier/v2/"
+	assert.NoError(proxy.Save("CCDD33ee", 1900000000))
+	assert.NoError(mock.Save("EEFF44aa", 2000000000), "[TestFileTokenStoreBaseURL] Expected the App Token to be replaced")
+
+	appToken, expiresAt, err := mock.Load()
+	assert.NoError(err)
+	assert.Equal("EEFF44aa", appToken, "[TestFileTokenStoreBaseURL] Expected the App Token saved for the base URL")
+	assert.Equal(int64(2000000000), expiresAt, "[TestFileTokenStoreBaseURL] Expected the expiry saved for the base URL")
+
+	appToken, expiresAt, err = proxy.Load()
+	assert.NoError(err)
+	assert.Equal("CCDD33ee", appToken, "[TestFileTokenStoreBaseURL] Expected the App Token of another base URL to be kept")
+	assert.Equal(int64(1900000000), expiresAt)
+
+	appToken, expiresAt, err = NewFileTokenStore(path).Load()
+	assert.NoError(err)
+	assert.Equal("ZZXX11ff", appToken, "[TestFileTokenStoreBaseURL] Expected the App Token for pushnotifier.de to stay intact")
+	assert.Equal(int64(1700000000), expiresAt)
+
+	v, err := readConfigFile(path)
+	assert.NoError(err)
+	assert.Len(v.Get("app_tokens"), 2, "[TestFileTokenStoreBaseURL] Expected one App Token per base URL")
+}
+
+func TestUpdateConfigFile(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "pushnotifier.yaml")
+	assert.NoError(os.WriteFile(path, []byte("package_name: dev.myapp.pn\naliases:\n  phone-alice: abc123\n  tablet-bob: def456\n"), 0600))
+
+	err := UpdateConfigFile(path, func(settings map[string]interface{}) error {
+		delete(settings["aliases"].(map[string]interface{}), "tablet-bob")
+		settings["user_name"] = "aUser"
+		return nil
+	})
+	assert.NoError(err, "[TestUpdateConfigFile] Expected the config file to be updated")
+
+	v, err := readConfigFile(path)
+	assert.NoError(err)
+	assert.Equal("dev.myapp.pn", v.GetString("package_name"), "[TestUpdateConfigFile] Expected untouched settings to be kept")
+	assert.Equal("aUser", v.GetString("user_name"), "[TestUpdateConfigFile] Expected the new setting to be written")
+	assert.Equal(map[string]string{"phone-alice": "abc123"}, v.GetStringMapString("aliases"), "[TestUpdateConfigFile] Expected the deleted nested key to be removed")
+
+	_, err = os.Stat(path + ".lock")
+	assert.True(os.IsNotExist(err), "[TestUpdateConfigFile] Expected the lock file to be released")
+}
@@ -25,21 +25,24 @@ package pushnotifier
 
 import (
 	"bytes"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
 type (
 	// Client represents a client interface to the pushnotifier.de API endpoint and the necessary tokens for interaction.
+	//
+	// A Client is safe for concurrent use by multiple goroutines. UserName,
+	// AppToken, AppTokenExpiry and Devices are updated by the client itself, so
+	// once it is in use they must only be accessed through Token, SetToken and DeviceIDs.
 	Client struct {
 		client         *http.Client
 		APIToken       string
@@ -48,7 +51,32 @@ type (
 		UserName       string
 		AppToken       string
 		AppTokenExpiry int64
-		Devices        []string
+		// Devices holds the IDs of the devices cached by the client.
+		Devices []string
+		// Logger receives diagnostic messages. It defaults to a no-op logger.
+		Logger Logger
+		// RetryPolicy configures retrying failed requests. Requests are not retried if it is nil.
+		RetryPolicy *RetryPolicy
+		// RateLimiter, if set, is waited on before every request, including retries.
+		RateLimiter Limiter
+
+		userAgent  string
+		tokenStore TokenStore
+		avatar     string
+		overflow   OverflowPolicy
+
+		// mu guards UserName, avatar, AppToken, AppTokenExpiry, Devices, the device cache and refreshing.
+		mu               sync.RWMutex
+		refreshing       *refreshCall
+		deviceCache      []Device
+		deviceCacheTTL   time.Duration
+		devicesFetchedAt time.Time
+	}
+
+	// refreshCall is an in-flight `user/refresh` request that concurrent callers wait on.
+	refreshCall struct {
+		done chan struct{}
+		err  error
 	}
 
 	User struct {
@@ -72,102 +100,146 @@ type (
 )
 
 const (
-	endpoint = "https://api.pushnotifier.de/v2/"
+	// DefaultBaseURL is the base URL of the pushnotifier.de API, used unless configured with WithBaseURL.
+	DefaultBaseURL = "https://api.pushnotifier.de/v2/"
+
+	// RefreshWindow is how long before its expiry an App Token is refreshed.
+	RefreshWindow = 1_000 * time.Second
 )
 
-// A NewClient creates a new PushNotifier API client. It expects 3 arguments
+// A NewClient creates a new PushNotifier API client. It expects 4 arguments
 // 1) a `http.Client`
-// 2) an API token
-// 3) a package name
+// 2) a package name
+// 3) an API token
+// 4) an App Token, which is never refreshed, or an empty string
 //
 // Currently, the 1st argument will default to `http.DefaultClient` if no
 // arguments are given.
+//
+// NewClient is kept for compatibility, New with options should be preferred.
 // For more information on pushnotifier.de: https://api.pushnotifier.de/v2/doc/
 func NewClient(httpClient *http.Client, packageName, token, appToken string) *Client {
-	if httpClient == nil {
-		httpClient = &http.Client{}
+	opts := make([]Option, 0, 2)
+	if httpClient != nil {
+		opts = append(opts, WithHTTPClient(httpClient))
 	}
-
-	baseURL, _ := url.Parse(endpoint)
-
 	if appToken != "" {
-		return &Client{
-			client:         httpClient,
-			APIToken:       token,
-			BaseURL:        baseURL,
-			PackageName:    packageName,
-			UserName:       "",
-			AppToken:       appToken,
-			AppTokenExpiry: -1,
-			Devices:        make([]string, 0),
-		}
+		opts = append(opts, WithAppToken(appToken, time.Time{}))
 	}
-	return &Client{
-		client:         httpClient,
-		APIToken:       token,
-		BaseURL:        baseURL,
-		PackageName:    packageName,
-		UserName:       "",
-		AppToken:       "",
-		AppTokenExpiry: 0,
-		Devices:        make([]string, 0),
+
+	// None of the options above can fail.
+	c, _ := New(packageName, token, opts...)
+	return c
+}
+
+// logger returns c.Logger, or a no-op logger if none was set.
+func (c *Client) logger() Logger {
+	if c.Logger == nil {
+		return nopLogger{}
 	}
+	return c.Logger
 }
 
-func (c *Client) request(method, resource string, formData io.Reader) (*http.Response, error) {
-	req, err := http.NewRequest(method, resource, formData)
+func (c *Client) request(ctx context.Context, method, resource string, formData io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, resource, formData)
 	if err != nil {
 		return nil, err
 	}
 
-	// If resource does not contain and or is for "login", try refreshing
-	if !strings.Contains(resource, "login") && c.shouldRefresh() {
-		c.RefreshToken()
+	if body, ok := formData.(*streamBody); ok {
+		req.ContentLength = body.size
+		if body.reopen != nil {
+			req.GetBody = body.reopen
+		}
+	}
+
+	endpoint := strings.TrimPrefix(strings.TrimPrefix(resource, c.BaseURL.String()), "/")
+
+	// Logging in does not need an App Token, and refreshing it must not recurse into another refresh.
+	if endpoint != "login" && endpoint != "user/refresh" {
+		if err := c.refreshIfNeeded(ctx); err != nil {
+			c.logger().Warn("[request] unable to refresh App Token", "error", err)
+		}
 	}
 
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	if c.AppToken != "" {
-		req.Header.Set("X-AppToken", c.AppToken)
+	if appToken, _ := c.Token(); appToken != "" {
+		req.Header.Set("X-AppToken", appToken)
+	}
+
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
 	}
 
 	req.SetBasicAuth(c.PackageName, c.APIToken)
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
+	for attempt := 1; ; attempt++ {
+		if c.RateLimiter != nil {
+			if err := c.RateLimiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
 
-	if resp.StatusCode != 200 {
-		respBody, _ := io.ReadAll(resp.Body)
-		defer resp.Body.Close()
+		resp, err := c.client.Do(req)
+		if err == nil && resp.StatusCode == 200 {
+			return resp, nil
+		}
 
-		return nil, fmt.Errorf("%v - %v", resp.Status, string(respBody))
+		if err == nil {
+			respBody, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
 
-	}
+			err = newAPIError(method, endpoint, resp, respBody)
+		} else {
+			resp = nil
+		}
+
+		wait, retry := c.RetryPolicy.retryAfter(method, attempt, resp, err)
+		if !retry || req.Body != nil && req.GetBody == nil {
+			return nil, err
+		}
 
-	return resp, nil
+		c.logger().Warn("[request] Retrying failed request", "endpoint", endpoint, "attempt", attempt, "wait", wait, "error", err)
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+
+		// The body of the previous attempt has been consumed, so send a fresh copy of it.
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+	}
 }
 
 // Login is used to login on behalf of a user. Logging in means to obtain a so-called "Appp Token" which is used to identify your requests.
 func (c *Client) Login(username, password string) error {
+	return c.LoginContext(context.Background(), username, password)
+}
+
+// LoginContext is like Login but aborts the request when ctx is done.
+func (c *Client) LoginContext(ctx context.Context, username, password string) error {
 	resource, err := c.BaseURL.Parse("login")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	if username == "" || password == "" {
-		log.Fatal("[Login] username and password is required to obtain App Token")
+		return errors.New("[Login] username and password is required to obtain App Token")
 	}
 
-	c.UserName = username
-	loginData := map[string]string{"username": c.UserName, "password": password}
+	loginData := map[string]string{"username": username, "password": password}
 
 	formData, err := json.Marshal(loginData)
 	if err != nil {
 		return fmt.Errorf("[Login] unable to create form data to send: %v", err.Error())
 	}
 
-	resp, err := c.request("POST", resource.String(), bytes.NewBuffer(formData))
+	resp, err := c.request(ctx, "POST", resource.String(), bytes.NewBuffer(formData))
 	if err != nil {
 		return err
 	}
@@ -179,323 +251,234 @@ func (c *Client) Login(username, password string) error {
 		return fmt.Errorf("[Login] unable to decode response body as JSON: %v", err.Error())
 	}
 
+	c.mu.Lock()
+	c.UserName = username
+	c.avatar = user.Avatar
 	c.AppToken = user.AppToken
 	c.AppTokenExpiry = user.ExpiresAt
-	log.Println("[Login] App Token for user obtained")
+	c.mu.Unlock()
 
-	return nil
-}
+	c.logger().Info("[Login] App Token for user obtained")
+	c.logger().Debug("[Login] Logged in", "username", username, "expires_at", time.Unix(user.ExpiresAt, 0).UTC())
 
-func (c *Client) shouldRefresh() bool {
-	// if we provided an appToken/APP_TOKEN to NewClient, that means we do not need to refresh token.
-	if c.AppTokenExpiry == -1 {
-		return false
+	if err := c.saveToken(user.AppToken, user.ExpiresAt); err != nil {
+		return fmt.Errorf("[Login] %w", err)
 	}
 
-	timeNow := time.Now().UTC().Unix()
+	return nil
+}
 
-	timeLeft := c.AppTokenExpiry - timeNow
+// Token returns the current App Token and its expiry as a unix timestamp.
+// An expiry of -1 means the token is never refreshed by the client.
+func (c *Client) Token() (string, int64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	// if the token expiry time is lower than 1_000 seconds, it is time to refresh the token.
-	return timeLeft < 1_000
+	return c.AppToken, c.AppTokenExpiry
 }
 
-// RefreshToken is used to refresh your obtain App Token.
-func (c *Client) RefreshToken() error {
-	resource, err := c.BaseURL.Parse("user/refresh")
-	if err != nil {
-		return err
-	}
+// SetToken replaces the App Token and its expiry as a unix timestamp. An expiry
+// of -1 means the token is never refreshed by the client.
+func (c *Client) SetToken(appToken string, expiry int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	resp, err := c.request("GET", resource.String(), nil)
-	if err != nil {
-		return err
-	}
+	c.AppToken = appToken
+	c.AppTokenExpiry = expiry
+}
 
-	var user *User
-	err = json.NewDecoder(resp.Body).Decode(&user)
-	if err != nil {
-		return fmt.Errorf("[RefreshToken] unable to decode response body as JSON: %v", err.Error())
+// saveToken saves the App Token to the token store, if the client has one.
+func (c *Client) saveToken(appToken string, expiresAt int64) error {
+	if c.tokenStore == nil {
+		return nil
 	}
 
-	c.AppToken = user.AppToken
-	c.AppTokenExpiry = user.ExpiresAt
-	log.Println("[RefreshToken] App Token for user obtained")
-
+	if err := c.tokenStore.Save(appToken, expiresAt); err != nil {
+		return fmt.Errorf("App Token obtained but could not be saved to token store: %w", err)
+	}
 	return nil
 }
 
-// GetDevices get all devices a user has registered and that are available for sending.
-func (c *Client) GetDevices() error {
-	resource, err := c.BaseURL.Parse("devices")
-	if err != nil {
-		return err
+// shouldRefresh must be called with c.mu held.
+func (c *Client) shouldRefresh() bool {
+	// if we provided an appToken/APP_TOKEN to NewClient, that means we do not need to refresh token.
+	if c.AppTokenExpiry == -1 {
+		return false
 	}
 
-	if c.shouldRefresh() {
-		c.RefreshToken()
-	}
+	timeNow := time.Now().UTC().Unix()
 
-	resp, err := c.request("GET", resource.String(), nil)
-	if err != nil {
-		return err
-	}
+	timeLeft := c.AppTokenExpiry - timeNow
 
-	var devices *[]Device
-	err = json.NewDecoder(resp.Body).Decode(&devices)
-	if err != nil {
-		return fmt.Errorf("[GetDevices] unable to decode response body as JSON: %v", err.Error())
-	}
+	// if the token expiry time is lower than RefreshWindow, it is time to refresh the token.
+	return timeLeft < int64(RefreshWindow/time.Second)
+}
 
-	log.Println("[GetDevices] Registered devices for user obtained")
+// RefreshToken is used to refresh your obtain App Token.
+//
+// Concurrent calls share a single request to the API.
+func (c *Client) RefreshToken() error {
+	return c.RefreshTokenContext(context.Background())
+}
 
-	// Append obtained devices' IDs to the Client struct for future use and print to user with full details.
-	// TODO: Append full metadata of devices, however, during sending notification to all devices just have a internal function that creates a slice of IDs.
-	for _, device := range *devices {
-		c.Devices = append(c.Devices, device.ID)
-	}
-	log.Println("Devices:", c.Devices)
+// RefreshTokenContext is like RefreshToken but aborts the request when ctx is done.
+func (c *Client) RefreshTokenContext(ctx context.Context) error {
+	return c.refresh(ctx, false)
+}
 
-	return nil
+// refreshIfNeeded refreshes the App Token if it is about to expire.
+func (c *Client) refreshIfNeeded(ctx context.Context) error {
+	return c.refresh(ctx, true)
 }
 
-// SendText sends a notification to all registered clients with a simple text.
-func (c *Client) SendText(content string, devices []string, silent bool) error {
-	resource, err := c.BaseURL.Parse("notifications/text")
-	if err != nil {
-		return err
+// refresh refreshes the App Token, or waits for the refresh already in flight.
+// With onlyIfNeeded, nothing is done if the token is not about to expire, which
+// is checked under the same lock as starting a refresh so that callers racing
+// on an expiring token only ever start a single refresh.
+func (c *Client) refresh(ctx context.Context, onlyIfNeeded bool) error {
+	c.mu.Lock()
+	if onlyIfNeeded && !c.shouldRefresh() {
+		c.mu.Unlock()
+		return nil
 	}
 
-	if content == "" {
-		return errors.New("[SendText] content to send as notification was empty")
-	}
+	call := c.refreshing
+	if call == nil {
+		call = &refreshCall{done: make(chan struct{})}
+		c.refreshing = call
+		c.mu.Unlock()
 
-	if len(c.Devices) == 0 && len(devices) == 0 {
-		log.Println("[SendText] No devices given. Acquring devices...")
-		c.GetDevices()
-		devices = append(devices, c.Devices...)
-		log.Println(devices)
-	}
+		call.err = c.refreshToken(ctx)
 
-	sendData := struct {
-		Devices []string `json:"devices"`
-		Content string   `json:"content"`
-		Silent  bool     `json:"silent"`
-	}{
-		Devices: devices,
-		Content: content,
-		Silent:  silent,
-	}
+		c.mu.Lock()
+		c.refreshing = nil
+		c.mu.Unlock()
+		close(call.done)
 
-	formData, err := json.Marshal(sendData)
-	if err != nil {
-		return errors.New("[SendText] unable to create form data to send")
+		return call.err
 	}
+	c.mu.Unlock()
 
-	resp, err := c.request("PUT", resource.String(), bytes.NewBuffer(formData))
-	if err != nil {
-		return err
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-
-	var sResp serverRespSuccess
-	err = json.NewDecoder(resp.Body).Decode(&sResp)
-	if err != nil {
-		return fmt.Errorf("[SendText] unable to decode response body as JSON: %v", err.Error())
-	}
-
-	log.Println("[SendText]", sResp.Success)
-
-	return nil
 }
 
-// SendURL sends a notification to all registered clients with a URL.
-func (c *Client) SendURL(contentURL string, devices []string, silent bool) error {
-	resource, err := c.BaseURL.Parse("notifications/url")
+// refreshToken performs the `user/refresh` request.
+func (c *Client) refreshToken(ctx context.Context) error {
+	resource, err := c.BaseURL.Parse("user/refresh")
 	if err != nil {
 		return err
 	}
 
-	if contentURL == "" {
-		return errors.New("[SendURL] content URL to send as notification was empty")
-	}
-
-	parsedContentURL, err := url.Parse(contentURL)
+	resp, err := c.request(ctx, "GET", resource.String(), nil)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
-	if len(c.Devices) == 0 && devices == nil {
-		log.Println("[SendURL] No devices given. Acquring devices...")
-		c.GetDevices()
-		copy(devices, c.Devices)
-	}
-
-	sendData := struct {
-		Devices []string `json:"devices"`
-		URL     string   `json:"url"`
-		Silent  bool     `json:"silent"`
-	}{
-		Devices: devices,
-		URL:     parsedContentURL.String(),
-		Silent:  silent,
-	}
-
-	formData, err := json.Marshal(sendData)
+	var user *User
+	err = json.NewDecoder(resp.Body).Decode(&user)
 	if err != nil {
-		return errors.New("[SendURL] unable to create form data to send")
+		return fmt.Errorf("[RefreshToken] unable to decode response body as JSON: %v", err.Error())
 	}
 
-	resp, err := c.request("PUT", resource.String(), bytes.NewBuffer(formData))
-	if err != nil {
-		return err
+	c.mu.Lock()
+	if user.UserName != "" {
+		c.UserName = user.UserName
+		c.avatar = user.Avatar
 	}
+	c.AppToken = user.AppToken
+	c.AppTokenExpiry = user.ExpiresAt
+	c.mu.Unlock()
 
-	var sResp serverRespSuccess
-	err = json.NewDecoder(resp.Body).Decode(&sResp)
-	if err != nil {
-		return fmt.Errorf("[SendURL] unable to decode response body as JSON: %v", err.Error())
-	}
+	c.logger().Info("[RefreshToken] App Token for user obtained")
+	c.logger().Debug("[RefreshToken] App Token expiry", "expires_at", time.Unix(user.ExpiresAt, 0).UTC())
 
-	log.Println("[SendText]", sResp.Success)
+	if err := c.saveToken(user.AppToken, user.ExpiresAt); err != nil {
+		return fmt.Errorf("[RefreshToken] %w", err)
+	}
 
 	return nil
 }
 
-// SendNotification sends a notification to all registered clients with content or URL.
-func (c *Client) SendNotification(content, contentURL string, devices []string, silent bool) error {
-	resource, err := c.BaseURL.Parse("notifications/notification")
-	if err != nil {
-		return err
-	}
-
-	if c.shouldRefresh() {
-		c.RefreshToken()
-	}
-
-	if content == "" || contentURL == "" {
-		return errors.New("[SendNotification] content text or URL to send as notification was empty")
-	}
+// GetDevices get all devices a user has registered and that are available for sending.
+//
+// The devices replace those cached by the client, see CachedDevices.
+func (c *Client) GetDevices() ([]Device, error) {
+	return c.GetDevicesContext(context.Background())
+}
 
-	parsedContentURL, err := url.Parse(contentURL)
+// GetDevicesContext is like GetDevices but aborts the request when ctx is done.
+func (c *Client) GetDevicesContext(ctx context.Context) ([]Device, error) {
+	resource, err := c.BaseURL.Parse("devices")
 	if err != nil {
-		return err
-	}
-
-	if len(c.Devices) == 0 && devices == nil {
-		log.Println("[SendNotification] No devices given. Acquring devices...")
-		c.GetDevices()
-		copy(devices, c.Devices)
-	}
-
-	sendData := struct {
-		Devices []string `json:"devices"`
-		Content string   `json:"content"`
-		URL     string   `json:"url"`
-		Silent  bool     `json:"silent"`
-	}{
-		Devices: devices,
-		Content: content,
-		URL:     parsedContentURL.String(),
-		Silent:  silent,
+		return nil, err
 	}
 
-	formData, err := json.Marshal(sendData)
+	resp, err := c.request(ctx, "GET", resource.String(), nil)
 	if err != nil {
-		return errors.New("[SendNotification] unable to create form data to send")
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	resp, err := c.request("PUT", resource.String(), bytes.NewBuffer(formData))
+	var devices []Device
+	err = json.NewDecoder(resp.Body).Decode(&devices)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("[GetDevices] unable to decode response body as JSON: %v", err.Error())
 	}
 
-	var sResp serverRespSuccess
-	err = json.NewDecoder(resp.Body).Decode(&sResp)
-	if err != nil {
-		return fmt.Errorf("[SendNotification] unable to decode response body as JSON: %v", err.Error())
-	}
+	devices = uniqueDevices(devices)
+	c.logger().Info("[GetDevices] Registered devices for user obtained", "count", len(devices))
 
-	log.Println("[SendNotification]", sResp.Success)
+	c.cacheDevices(devices)
+	c.logger().Debug("[GetDevices] Devices", "devices", c.DeviceIDs())
 
-	return nil
+	return copyDevices(devices), nil
 }
 
-// SendImage sends a notification to all registered clients with an Image.
-func (c *Client) SendImage(contentFile string, devices []string, silent bool) error {
-	resource, err := c.BaseURL.Parse("notifications/image")
-	if err != nil {
-		return err
-	}
-
-	if c.shouldRefresh() {
-		c.RefreshToken()
-	}
-
-	if contentFile == "" {
-		return errors.New("[SendImage] content image file path to send as notification was empty")
-	}
-
-	osStat, err := os.Stat(contentFile)
-	if err != nil {
-		return err
-	}
-
-	// check if file path exists or not
-	if os.IsNotExist(err) {
-		return err
-	}
-
-	// check if given path is to a file
-	if osStat.IsDir() {
-		return errors.New("[SendImage] given image file path is a directory and not an actual file")
-	}
-
-	// check if file size is greater than 5_000_000 bytes or 5 Megabytes (MB)
-	if osStat.Size() > 5_000_000 {
-		return errors.New("[SendImage] given file size is greater than 5 MegaBytes (MB)")
-	}
-
-	fileRaw, err := os.ReadFile(contentFile)
-	if err != nil {
-		return err
-	}
-	encodedContent := base64.StdEncoding.EncodeToString(fileRaw)
+// SendText sends a notification to all registered clients with a simple text.
+// The returned SendResult lists the devices the notification was and was not
+// delivered to. If it was not delivered to any device, the error wraps ErrDeliveryFailed.
+func (c *Client) SendText(content string, devices []string, silent bool) (*SendResult, error) {
+	return c.SendTextContext(context.Background(), content, devices, silent)
+}
 
-	if len(c.Devices) == 0 && devices == nil {
-		log.Println("[SendImage] No devices given. Acquring devices...")
-		c.GetDevices()
-		copy(devices, c.Devices)
-	}
+// SendTextContext is like SendText but aborts the request when ctx is done.
+func (c *Client) SendTextContext(ctx context.Context, content string, devices []string, silent bool) (*SendResult, error) {
+	return c.Send(ctx, Notification{Kind: KindText, Text: content, Devices: devices, Silent: silent})
+}
 
-	sendData := struct {
-		Devices  []string `json:"devices"`
-		Content  string   `json:"content"`
-		Filename string   `json:"filename"`
-		Silent   bool     `json:"silent"`
-	}{
-		Devices:  devices,
-		Content:  encodedContent,
-		Filename: osStat.Name(),
-		Silent:   silent,
-	}
+// SendURL sends a notification to all registered clients with a URL.
+func (c *Client) SendURL(contentURL string, devices []string, silent bool) (*SendResult, error) {
+	return c.SendURLContext(context.Background(), contentURL, devices, silent)
+}
 
-	formData, err := json.Marshal(sendData)
-	if err != nil {
-		return errors.New("[SendImage] unable to create form data to send")
-	}
+// SendURLContext is like SendURL but aborts the request when ctx is done.
+func (c *Client) SendURLContext(ctx context.Context, contentURL string, devices []string, silent bool) (*SendResult, error) {
+	return c.Send(ctx, Notification{Kind: KindURL, URL: contentURL, Devices: devices, Silent: silent})
+}
 
-	resp, err := c.request("PUT", resource.String(), bytes.NewBuffer(formData))
-	if err != nil {
-		return err
-	}
+// SendNotification sends a notification to all registered clients with content or URL.
+// If only one of them is given, it is sent as a text or URL notification.
+func (c *Client) SendNotification(content, contentURL string, devices []string, silent bool) (*SendResult, error) {
+	return c.SendNotificationContext(context.Background(), content, contentURL, devices, silent)
+}
 
-	var sResp serverRespSuccess
-	err = json.NewDecoder(resp.Body).Decode(&sResp)
-	if err != nil {
-		return fmt.Errorf("[SendImage] unable to decode response body as JSON: %v", err.Error())
-	}
+// SendNotificationContext is like SendNotification but aborts the request when ctx is done.
+func (c *Client) SendNotificationContext(ctx context.Context, content, contentURL string, devices []string, silent bool) (*SendResult, error) {
+	return c.Send(ctx, Notification{Text: content, URL: contentURL, Devices: devices, Silent: silent})
+}
 
-	log.Println("[SendImage]", sResp.Success)
+// SendImage sends a notification to all registered clients with an Image.
+func (c *Client) SendImage(contentFile string, devices []string, silent bool) (*SendResult, error) {
+	return c.SendImageContext(context.Background(), contentFile, devices, silent)
+}
 
-	return nil
+// SendImageContext is like SendImage but aborts the request when ctx is done.
+func (c *Client) SendImageContext(ctx context.Context, contentFile string, devices []string, silent bool) (*SendResult, error) {
+	return c.Send(ctx, Notification{Kind: KindImage, Image: &Image{Path: contentFile}, Devices: devices, Silent: silent})
 }
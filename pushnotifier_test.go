@@ -22,6 +22,7 @@ THE SOFTWARE.
 package pushnotifier
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -30,6 +31,8 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -137,3 +140,81 @@ func TestLogin(t *testing.T) {
 
 	assert.Equal(wantAppToken, pn.AppToken, "[TestLogin] Expected wanted and recevied APP Token to be equal")
 }
+
+func TestGetDevicesContextDeadline(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// Never answer, so only the context deadline can end the request.
+	handler.HandleFunc("/devices", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := pn.GetDevicesContext(ctx)
+	assert.ErrorIs(err, context.DeadlineExceeded, "[TestGetDevicesContextDeadline] Expected request to be aborted by the context deadline")
+}
+
+func TestConcurrentSendsRefreshOnce(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	wantAppToken := "RefreshedZZXX11ff"
+
+	var refreshCalls int32
+	handler.HandleFunc("/user/refresh", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&refreshCalls, 1)
+		// Keep the refresh in flight long enough for every sender to pile up behind it.
+		time.Sleep(50 * time.Millisecond)
+
+		expiryTime := time.Now().UTC().AddDate(0, 0, 30).Unix()
+		fmt.Fprint(w, `{"username": "aUser", "app_token": "`+wantAppToken+`", "expires_at": `+strconv.FormatInt(expiryTime, 10)+`}`)
+	})
+	handler.HandleFunc("/devices", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": "abcd", "title": "Pixel", "model": "Pixel 6", "image": ""}]`)
+	})
+	handler.HandleFunc("/notifications/text", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-AppToken") != wantAppToken {
+			http.Error(w, "app token is invalid", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"success": ["abcd"], "error": []}`)
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "")
+	pn.BaseURL, _ = url.Parse(server.URL)
+	// An App Token about to expire makes every sender try to refresh it.
+	pn.SetToken("ZZXX11ff", time.Now().UTC().Unix()+10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			var devices []string
+			if i%2 == 0 {
+				devices = []string{"abcd"}
+			}
+
+			_, err := pn.SendText("hello world", devices, false)
+			assert.NoError(err, "[TestConcurrentSendsRefreshOnce] Expected concurrent send to succeed")
+		}(i)
+	}
+	wg.Wait()
+
+	appToken, _ := pn.Token()
+	assert.Equal(wantAppToken, appToken, "[TestConcurrentSendsRefreshOnce] Expected refreshed App Token to be used")
+	assert.Equal(int32(1), atomic.LoadInt32(&refreshCalls), "[TestConcurrentSendsRefreshOnce] Expected concurrent senders to share a single refresh")
+}
@@ -0,0 +1,493 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+
+// Package pushnotifiertest provides an in-process fake of the pushnotifier.de
+// v2 API, for testing code that sends notifications without reaching the
+// real service.
+//
+// The fake checks the basic auth credentials and App Token of every request like
+// the real API does, and records the notifications it receives:
+//
+//	server := pushnotifiertest.NewServer(pushnotifiertest.Config{})
+//	defer server.Close()
+//
+//	appToken, expiresAt := server.IssueToken(pushnotifiertest.DefaultUsername)
+//	pn, _ := pushnotifier.New(pushnotifiertest.DefaultPackageName, pushnotifiertest.DefaultAPIToken,
+//		pushnotifier.WithBaseURL(server.URL), pushnotifier.WithAppToken(appToken, expiresAt))
+//
+//	pn.SendText("hello world", nil, false)
+//	notifications := server.Notifications()
+package pushnotifiertest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials and settings a fake uses unless configured otherwise.
+const (
+	DefaultPackageName = "dev.pushnotifier.test"
+	DefaultAPIToken    = "pushnotifiertest-api-token"
+	DefaultUsername    = "user"
+	DefaultPassword    = "password"
+	DefaultTokenTTL    = 24 * time.Hour
+)
+
+// MaxImageSize is the largest image, in bytes, the fake accepts, like pushnotifier.de.
+const MaxImageSize = 5_000_000
+
+type (
+	// Config configures a fake. Its zero value accepts the default credentials
+	// and has two devices registered.
+	Config struct {
+		// PackageName and APIToken are the credentials expected in the basic auth of every request.
+		PackageName string
+		APIToken    string
+		// Users maps the names of the users that can log in to their passwords.
+		Users map[string]string
+		// Devices are the devices registered for every user.
+		Devices []Device
+		// TokenTTL is how long App Tokens issued by the fake are valid.
+		TokenTTL time.Duration
+		// OnNotification, if set, is called with every notification received, e.g. to persist it.
+		OnNotification func(Notification)
+	}
+
+	// Device is a device registered with the fake.
+	Device struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+		Model string `json:"model"`
+		Image string `json:"image"`
+	}
+
+	// Notification is a notification received by the fake.
+	Notification struct {
+		// Kind is the kind of the notification, i.e. "text", "url", "notification" or "image".
+		Kind     string    `json:"kind"`
+		Devices  []string  `json:"devices"`
+		Content  string    `json:"content,omitempty"`
+		URL      string    `json:"url,omitempty"`
+		Filename string    `json:"filename,omitempty"`
+		Image    []byte    `json:"image,omitempty"`
+		Silent   bool      `json:"silent"`
+		Username string    `json:"username"`
+		Received time.Time `json:"received"`
+	}
+
+	// Fake is an http.Handler serving a fake of the pushnotifier.de v2 API. It is
+	// safe for concurrent use. Its endpoints are served both at the root and
+	// below /v2/, like the real API.
+	Fake struct {
+		config Config
+
+		mu            sync.Mutex
+		tokens        map[string]appToken
+		notifications []Notification
+		failures      []failure
+		rejected      map[string]bool
+		latency       time.Duration
+	}
+
+	// Server is a Fake listening on a local port, see NewServer.
+	Server struct {
+		*Fake
+
+		// URL is the base URL of the API, to be used with pushnotifier.WithBaseURL.
+		URL string
+
+		server *httptest.Server
+	}
+
+	// appToken is an App Token issued by the fake.
+	appToken struct {
+		username  string
+		expiresAt time.Time
+	}
+
+	// failure is a failure injected with Fail.
+	failure struct {
+		endpoint string
+		status   int
+		times    int
+	}
+)
+
+// NewFake returns a fake configured by config.
+func NewFake(config Config) *Fake {
+	if config.PackageName == "" {
+		config.PackageName = DefaultPackageName
+	}
+	if config.APIToken == "" {
+		config.APIToken = DefaultAPIToken
+	}
+	if config.Users == nil {
+		config.Users = map[string]string{DefaultUsername: DefaultPassword}
+	}
+	if config.Devices == nil {
+		config.Devices = []Device{
+			{ID: "dev1", Title: "Phone", Model: "Pixel 6"},
+			{ID: "dev2", Title: "Tablet", Model: "iPad Air"},
+		}
+	}
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = DefaultTokenTTL
+	}
+
+	return &Fake{
+		config:   config,
+		tokens:   make(map[string]appToken),
+		rejected: make(map[string]bool),
+	}
+}
+
+// NewServer starts a fake configured by config on a local port. It must be closed with Close.
+func NewServer(config Config) *Server {
+	fake := NewFake(config)
+	server := httptest.NewServer(fake)
+
+	return &Server{
+		Fake:   fake,
+		URL:    server.URL + "/v2/",
+		server: server,
+	}
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// IssueToken returns a new App Token for username, as if the user had logged in.
+func (f *Fake) IssueToken(username string) (token string, expiresAt time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.issueToken(username)
+}
+
+// AddToken makes the fake accept token as an App Token of username until
+// expiresAt, e.g. to accept a token obtained earlier. A zero expiresAt never expires.
+func (f *Fake) AddToken(token, username string, expiresAt time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tokens[token] = appToken{username: username, expiresAt: expiresAt}
+}
+
+// issueToken must be called with f.mu held.
+func (f *Fake) issueToken(username string) (string, time.Time) {
+	raw := make([]byte, 16)
+	rand.Read(raw)
+
+	token := hex.EncodeToString(raw)
+	// The API reports expiry in whole seconds.
+	expiresAt := time.Now().Add(f.config.TokenTTL).Truncate(time.Second)
+	f.tokens[token] = appToken{username: username, expiresAt: expiresAt}
+
+	return token, expiresAt
+}
+
+// ExpireTokens expires all App Tokens issued so far, so that requests made with them fail as unauthorized.
+func (f *Fake) ExpireTokens() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for token, info := range f.tokens {
+		info.expiresAt = time.Now().Add(-time.Second)
+		f.tokens[token] = info
+	}
+}
+
+// Fail makes the next times requests to endpoint, e.g. "notifications/text",
+// fail with the given HTTP status code. An empty endpoint matches every request.
+func (f *Fake) Fail(endpoint string, status, times int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures = append(f.failures, failure{endpoint: strings.Trim(endpoint, "/"), status: status, times: times})
+}
+
+// SetLatency delays every response by d.
+func (f *Fake) SetLatency(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.latency = d
+}
+
+// Reject makes notifications to the devices with the given IDs fail, as if they could not be reached.
+func (f *Fake) Reject(deviceIDs ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range deviceIDs {
+		f.rejected[id] = true
+	}
+}
+
+// Notifications returns the notifications received so far, oldest first.
+func (f *Fake) Notifications() []Notification {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	notifications := make([]Notification, len(f.notifications))
+	copy(notifications, f.notifications)
+	return notifications
+}
+
+// Reset forgets the notifications received, and drops injected failures, latency and rejected devices.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.notifications = nil
+	f.failures = nil
+	f.rejected = make(map[string]bool)
+	f.latency = 0
+}
+
+// ServeHTTP serves a request to the API.
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"), "v2/")
+
+	latency, status := f.injected(endpoint)
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if status != 0 {
+		writeError(w, status)
+		return
+	}
+
+	packageName, apiToken, ok := r.BasicAuth()
+	if !ok || packageName != f.config.PackageName || apiToken != f.config.APIToken {
+		writeError(w, http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case endpoint == "login" && r.Method == http.MethodPost:
+		f.login(w, r)
+		return
+	case endpoint == "login":
+		writeError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	username, ok := f.authenticate(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized)
+		return
+	}
+
+	switch endpoint {
+	case "user/refresh":
+		f.refresh(w, r, username)
+	case "devices":
+		writeJSON(w, f.config.Devices)
+	case "notifications/text", "notifications/url", "notifications/notification", "notifications/image":
+		if r.Method != http.MethodPut {
+			writeError(w, http.StatusMethodNotAllowed)
+			return
+		}
+		f.notify(w, r, strings.TrimPrefix(endpoint, "notifications/"), username)
+	default:
+		writeError(w, http.StatusNotFound)
+	}
+}
+
+// injected returns the latency to respond with, and the status code of an injected failure for endpoint or 0.
+func (f *Fake) injected(endpoint string) (time.Duration, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.failures {
+		failure := &f.failures[i]
+		if failure.times <= 0 || failure.endpoint != "" && failure.endpoint != endpoint {
+			continue
+		}
+
+		failure.times--
+		return f.latency, failure.status
+	}
+	return f.latency, 0
+}
+
+// authenticate returns the user the App Token of r belongs to, if it is valid.
+func (f *Fake) authenticate(r *http.Request) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, ok := f.tokens[r.Header.Get("X-AppToken")]
+	if !ok || !info.expiresAt.IsZero() && time.Now().After(info.expiresAt) {
+		return "", false
+	}
+	return info.username, true
+}
+
+func (f *Fake) login(w http.ResponseWriter, r *http.Request) {
+	var credentials struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	password, ok := f.config.Users[credentials.Username]
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound)
+		return
+	case password != credentials.Password:
+		writeError(w, http.StatusForbidden)
+		return
+	}
+
+	token, expiresAt := f.IssueToken(credentials.Username)
+	writeJSON(w, map[string]interface{}{
+		"username":   credentials.Username,
+		"avatar":     "",
+		"app_token":  token,
+		"expires_at": expiresAt.Unix(),
+	})
+}
+
+func (f *Fake) refresh(w http.ResponseWriter, r *http.Request, username string) {
+	f.mu.Lock()
+	delete(f.tokens, r.Header.Get("X-AppToken"))
+	token, expiresAt := f.issueToken(username)
+	f.mu.Unlock()
+
+	writeJSON(w, map[string]interface{}{
+		"app_token":  token,
+		"expires_at": expiresAt.Unix(),
+	})
+}
+
+func (f *Fake) notify(w http.ResponseWriter, r *http.Request, kind, username string) {
+	// A base64 encoded image of MaxImageSize and the rest of the body fit easily into maxBody.
+	const maxBody = 2 * MaxImageSize
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	switch {
+	case err != nil:
+		writeError(w, http.StatusBadRequest)
+		return
+	case len(body) > maxBody:
+		writeError(w, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var payload struct {
+		Devices  []string `json:"devices"`
+		Content  string   `json:"content"`
+		URL      string   `json:"url"`
+		Filename string   `json:"filename"`
+		Silent   bool     `json:"silent"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	notification := Notification{
+		Kind:     kind,
+		Devices:  payload.Devices,
+		Silent:   payload.Silent,
+		Username: username,
+		Received: time.Now(),
+	}
+
+	valid := len(payload.Devices) > 0
+	switch kind {
+	case "text":
+		notification.Content = payload.Content
+		valid = valid && payload.Content != ""
+	case "url":
+		notification.URL = payload.URL
+		valid = valid && payload.URL != ""
+	case "notification":
+		notification.Content, notification.URL = payload.Content, payload.URL
+		valid = valid && payload.Content != "" && payload.URL != ""
+	case "image":
+		image, err := base64.StdEncoding.DecodeString(payload.Content)
+		if err == nil && len(image) > MaxImageSize {
+			writeError(w, http.StatusRequestEntityTooLarge)
+			return
+		}
+		notification.Filename, notification.Image = payload.Filename, image
+		valid = valid && err == nil && len(image) > 0 && payload.Filename != ""
+	}
+	if !valid {
+		writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	registered := make(map[string]bool, len(f.config.Devices))
+	for _, device := range f.config.Devices {
+		registered[device.ID] = true
+	}
+
+	f.mu.Lock()
+	delivered, failed := make([]string, 0), make([]string, 0)
+	for _, id := range payload.Devices {
+		if registered[id] && !f.rejected[id] {
+			delivered = append(delivered, id)
+		} else {
+			failed = append(failed, id)
+		}
+	}
+	f.notifications = append(f.notifications, notification)
+	f.mu.Unlock()
+
+	if f.config.OnNotification != nil {
+		f.config.OnNotification(notification)
+	}
+
+	writeJSON(w, map[string]interface{}{"success": delivered, "error": failed})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"status": "error", "code": %d, "message": %q}`, status, http.StatusText(status))
+}
@@ -0,0 +1,178 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifiertest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mavjs/pushnotifier"
+	"github.com/mavjs/pushnotifier/pushnotifiertest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newClient returns a client of server, logged in as the default user.
+func newClient(t *testing.T, server *pushnotifiertest.Server) *pushnotifier.Client {
+	appToken, expiresAt := server.IssueToken(pushnotifiertest.DefaultUsername)
+
+	pn, err := pushnotifier.New(pushnotifiertest.DefaultPackageName, pushnotifiertest.DefaultAPIToken,
+		pushnotifier.WithBaseURL(server.URL), pushnotifier.WithAppToken(appToken, expiresAt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pn
+}
+
+func TestLogin(t *testing.T) {
+	assert := assert.New(t)
+
+	server := pushnotifiertest.NewServer(pushnotifiertest.Config{})
+	defer server.Close()
+
+	pn, _ := pushnotifier.New(pushnotifiertest.DefaultPackageName, pushnotifiertest.DefaultAPIToken, pushnotifier.WithBaseURL(server.URL))
+
+	assert.ErrorIs(pn.Login(pushnotifiertest.DefaultUsername, "wrong"), pushnotifier.ErrForbidden, "[TestLogin] Expected wrong password to be forbidden")
+	assert.ErrorIs(pn.Login("nobody", "password"), pushnotifier.ErrNotFound, "[TestLogin] Expected unknown user to not be found")
+	assert.NoError(pn.Login(pushnotifiertest.DefaultUsername, pushnotifiertest.DefaultPassword), "[TestLogin] Expected default credentials to log in")
+
+	devices, err := pn.GetDevices()
+	assert.NoError(err, "[TestLogin] Expected App Token obtained by logging in to be accepted")
+	assert.Len(devices, 2)
+
+	assert.NoError(pn.RefreshToken(), "[TestLogin] Expected App Token to be refreshed")
+	_, err = pn.GetDevices()
+	assert.NoError(err, "[TestLogin] Expected refreshed App Token to be accepted")
+
+	pn, _ = pushnotifier.New(pushnotifiertest.DefaultPackageName, "wrong", pushnotifier.WithBaseURL(server.URL))
+	assert.ErrorIs(pn.Login(pushnotifiertest.DefaultUsername, pushnotifiertest.DefaultPassword), pushnotifier.ErrUnauthorized, "[TestLogin] Expected wrong API token to be unauthorized")
+}
+
+func TestNotifications(t *testing.T) {
+	assert := assert.New(t)
+
+	server := pushnotifiertest.NewServer(pushnotifiertest.Config{})
+	defer server.Close()
+
+	pn := newClient(t, server)
+
+	_, err := pn.SendText("hello world", nil, true)
+	assert.NoError(err)
+	_, err = pn.SendNotification("build failed", "https://ci.example.com", []string{"dev1"}, false)
+	assert.NoError(err)
+	_, err = pn.SendImageBytes([]byte("\x89PNG\r\n\x1a\n"), "chart.png", []string{"dev2"}, false)
+	assert.NoError(err)
+
+	notifications := server.Notifications()
+	if assert.Len(notifications, 3, "[TestNotifications] Expected notifications to be recorded") {
+		assert.Equal("text", notifications[0].Kind)
+		assert.Equal("hello world", notifications[0].Content)
+		assert.Equal([]string{"dev1", "dev2"}, notifications[0].Devices)
+		assert.True(notifications[0].Silent)
+
+		assert.Equal("notification", notifications[1].Kind)
+		assert.Equal("https://ci.example.com", notifications[1].URL)
+
+		assert.Equal("image", notifications[2].Kind)
+		assert.Equal("chart.png", notifications[2].Filename)
+		assert.Equal([]byte("\x89PNG\r\n\x1a\n"), notifications[2].Image, "[TestNotifications] Expected image to be recorded decoded")
+	}
+
+	server.Reject("dev2")
+	result, err := pn.SendText("hello world", []string{"dev1", "dev2", "dev3"}, false)
+	assert.NoError(err)
+	assert.Equal([]string{"dev1"}, result.Delivered)
+	assert.Equal([]string{"dev2", "dev3"}, result.FailedIDs(), "[TestNotifications] Expected rejected and unknown devices to fail")
+
+	server.Reset()
+	assert.Empty(server.Notifications(), "[TestNotifications] Expected Reset to forget notifications")
+}
+
+func TestInjectedFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	server := pushnotifiertest.NewServer(pushnotifiertest.Config{})
+	defer server.Close()
+
+	pn := newClient(t, server)
+
+	server.Fail("notifications/text", http.StatusServiceUnavailable, 1)
+	_, err := pn.SendText("hello world", []string{"dev1"}, false)
+	assert.ErrorIs(err, pushnotifier.ErrServer, "[TestInjectedFailures] Expected injected failure to be returned")
+
+	_, err = pn.SendText("hello world", []string{"dev1"}, false)
+	assert.NoError(err, "[TestInjectedFailures] Expected failure to be injected only as often as requested")
+
+	server.SetLatency(200 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = pn.GetDevicesContext(ctx)
+	assert.ErrorIs(err, context.DeadlineExceeded, "[TestInjectedFailures] Expected latency to be injected")
+	server.SetLatency(0)
+
+	server.AddToken("ZZXX11ff", pushnotifiertest.DefaultUsername, time.Time{})
+	pn, _ = pushnotifier.New(pushnotifiertest.DefaultPackageName, pushnotifiertest.DefaultAPIToken,
+		pushnotifier.WithBaseURL(server.URL), pushnotifier.WithAppToken("ZZXX11ff", time.Time{}))
+	_, err = pn.GetDevices()
+	assert.NoError(err, "[TestInjectedFailures] Expected added App Token to be accepted")
+
+	server.ExpireTokens()
+	_, err = pn.GetDevices()
+	assert.ErrorIs(err, pushnotifier.ErrUnauthorized, "[TestInjectedFailures] Expected expired App Token to be unauthorized")
+}
+
+func TestImageLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	server := pushnotifiertest.NewServer(pushnotifiertest.Config{})
+	defer server.Close()
+
+	appToken, _ := server.IssueToken(pushnotifiertest.DefaultUsername)
+	image := base64.StdEncoding.EncodeToString(make([]byte, pushnotifiertest.MaxImageSize+1))
+	body := fmt.Sprintf(`{"devices": ["dev1"], "filename": "big.png", "content": %q}`, image)
+
+	req, _ := http.NewRequest(http.MethodPut, server.URL+"notifications/image", strings.NewReader(body))
+	req.SetBasicAuth(pushnotifiertest.DefaultPackageName, pushnotifiertest.DefaultAPIToken)
+	req.Header.Set("X-AppToken", appToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if assert.NoError(err) {
+		resp.Body.Close()
+		assert.Equal(http.StatusRequestEntityTooLarge, resp.StatusCode, "[TestImageLimit] Expected image larger than 5 MB to be rejected")
+	}
+	assert.Empty(server.Notifications())
+
+	req, _ = http.NewRequest(http.MethodPut, server.URL+"notifications/image", bytes.NewReader([]byte(`{"devices": ["dev1"]}`)))
+	req.SetBasicAuth(pushnotifiertest.DefaultPackageName, pushnotifiertest.DefaultAPIToken)
+	req.Header.Set("X-AppToken", appToken)
+
+	resp, err = http.DefaultClient.Do(req)
+	if assert.NoError(err) {
+		resp.Body.Close()
+		assert.Equal(http.StatusBadRequest, resp.StatusCode, "[TestImageLimit] Expected image notification without image to be rejected")
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter throttles the requests made by a Client. Wait is called before every
+// request, including retries, and must be safe for concurrent use.
+type Limiter interface {
+	// Wait blocks until a request may be made, or returns an error if it may not be made at all.
+	Wait(ctx context.Context) error
+}
+
+// RateLimiter is a token bucket Limiter allowing a steady number of requests per
+// second, with bursts of up to a fixed number of requests. It is safe for
+// concurrent use, so a single RateLimiter can be shared by several clients.
+type RateLimiter struct {
+	// FailFast makes Wait return an error wrapping ErrRateLimited instead of blocking until a request may be made.
+	FailFast bool
+
+	mu     sync.Mutex
+	rate   float64
+	burst  int
+	bucket TokenBucket
+}
+
+// TokenBucket is the state of a token bucket. It is exported for Limiter
+// implementations persisting it, e.g. to share a budget between processes.
+type TokenBucket struct {
+	// Tokens is the number of requests that may currently be made.
+	Tokens float64 `json:"tokens"`
+	// Last is the time Tokens was last refilled.
+	Last time.Time `json:"last"`
+}
+
+// NewRateLimiter creates a RateLimiter allowing requestsPerSecond requests per
+// second on average, and up to burst requests at once. A requestsPerSecond of
+// zero or less disables limiting.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		rate:   requestsPerSecond,
+		burst:  burst,
+		bucket: TokenBucket{Tokens: float64(burst), Last: time.Now()},
+	}
+}
+
+// Wait blocks until a request may be made or ctx is done. With FailFast set it
+// returns an error wrapping ErrRateLimited if no request may be made right away.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		wait := l.bucket.Take(l.rate, l.burst, time.Now())
+		l.mu.Unlock()
+
+		if wait == 0 {
+			return nil
+		}
+
+		if l.FailFast {
+			return fmt.Errorf("[RateLimiter] no request allowed for another %v: %w", wait.Round(time.Millisecond), ErrRateLimited)
+		}
+
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Take refills the bucket at rate tokens per second, up to burst tokens, and
+// then takes a token. It returns 0 if a token was taken, or otherwise how long
+// it takes until one is available. A rate of zero or less always allows taking a token.
+func (b *TokenBucket) Take(rate float64, burst int, now time.Time) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+
+	if elapsed := now.Sub(b.Last); elapsed > 0 {
+		b.Tokens = math.Min(float64(burst), b.Tokens+elapsed.Seconds()*rate)
+		b.Last = now
+	}
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return 0
+	}
+
+	return time.Duration((1 - b.Tokens) / rate * float64(time.Second))
+}
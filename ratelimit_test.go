@@ -0,0 +1,81 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2022, 9, 28, 12, 0, 0, 0, time.UTC)
+	bucket := TokenBucket{Tokens: 2, Last: now}
+
+	assert.Zero(bucket.Take(1, 2, now))
+	assert.Zero(bucket.Take(1, 2, now))
+	assert.Equal(time.Second, bucket.Take(1, 2, now), "[TestTokenBucketTake] Expected to wait for the next token once the burst is used up")
+
+	assert.Zero(bucket.Take(1, 2, now.Add(time.Second)), "[TestTokenBucketTake] Expected a token to be refilled after a second")
+
+	// The bucket never holds more than burst tokens, however long it was idle.
+	later := now.Add(time.Hour)
+	assert.Zero(bucket.Take(1, 2, later))
+	assert.Zero(bucket.Take(1, 2, later))
+	assert.NotZero(bucket.Take(1, 2, later))
+}
+
+func TestRateLimiterFailFast(t *testing.T) {
+	assert := assert.New(t)
+
+	limiter := NewRateLimiter(0.001, 1)
+	limiter.FailFast = true
+
+	assert.NoError(limiter.Wait(context.Background()))
+	assert.ErrorIs(limiter.Wait(context.Background()), ErrRateLimited, "[TestRateLimiterFailFast] Expected ErrRateLimited once the burst is used up")
+}
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	limiter := NewRateLimiter(100, 5)
+
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 15; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(limiter.Wait(context.Background()))
+		}()
+	}
+	wg.Wait()
+
+	// 5 requests are allowed by the burst, the other 10 at 100 per second.
+	assert.GreaterOrEqual(time.Since(start), 90*time.Millisecond, "[TestRateLimiterConcurrent] Expected requests beyond the burst to be throttled")
+}
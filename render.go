@@ -0,0 +1,264 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// RenderOptions configures how RenderText lays out text. The zero value uses the defaults.
+type RenderOptions struct {
+	// Scale is the size, in pixels, each pixel of the font is drawn with. It defaults to 2.
+	Scale int
+	// Columns is the number of characters after which a line is wrapped. It defaults to 120.
+	Columns int
+	// MaxLines is the number of lines rendered at most. Of a longer text only the
+	// last lines are rendered, as they are usually the interesting ones of a log.
+	// It defaults to 200.
+	MaxLines int
+}
+
+const (
+	// glyphWidth and glyphHeight are the size of a character of the font, in font pixels.
+	glyphWidth  = 5
+	glyphHeight = 7
+	// cellWidth and cellHeight are the size a character takes up, including the space to its neighbours.
+	cellWidth  = glyphWidth + 1
+	cellHeight = glyphHeight + 3
+	// tabWidth is the number of columns between two tab stops.
+	tabWidth = 4
+)
+
+// renderPalette holds the background and foreground colours of rendered text, like those of a terminal.
+var renderPalette = color.Palette{
+	color.RGBA{0x1e, 0x1e, 0x1e, 0xff},
+	color.RGBA{0xe0, 0xe0, 0xe0, 0xff},
+}
+
+// RenderText renders text, e.g. the last lines of a log, as a PNG image using
+// a monospace bitmap font, so that it can be sent with SendImageBytes when it
+// is too long to be read in a text notification. Only printable ASCII
+// characters can be rendered, every other character is drawn as '?'.
+func RenderText(text string, opts RenderOptions) ([]byte, error) {
+	if opts.Scale <= 0 {
+		opts.Scale = 2
+	}
+	if opts.Columns <= 0 {
+		opts.Columns = 120
+	}
+	if opts.MaxLines <= 0 {
+		opts.MaxLines = 200
+	}
+
+	lines := layoutText(text, opts.Columns)
+	if len(lines) == 0 {
+		return nil, errors.New("[RenderText] text to render was empty")
+	}
+	if len(lines) > opts.MaxLines {
+		omitted := len(lines) - opts.MaxLines + 1
+		lines = append([]string{fmt.Sprintf("[... %v earlier lines]", omitted)}, lines[omitted:]...)
+	}
+
+	columns := 0
+	for _, line := range lines {
+		if len(line) > columns {
+			columns = len(line)
+		}
+	}
+
+	scale := opts.Scale
+	padding := 4 * scale
+	width := 2*padding + columns*cellWidth*scale
+	height := 2*padding + len(lines)*cellHeight*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, width, height), renderPalette)
+	for row, line := range lines {
+		for column := 0; column < len(line); column++ {
+			drawGlyph(img, line[column], padding+column*cellWidth*scale, padding+row*cellHeight*scale, scale)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("[RenderText] unable to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// layoutText splits text into lines of at most columns printable ASCII
+// characters, expanding tabs and dropping trailing empty lines.
+func layoutText(text string, columns int) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var lines []string
+	for _, raw := range strings.Split(text, "\n") {
+		var line []byte
+		for _, r := range raw {
+			switch {
+			case r == '\t':
+				for len(line)%tabWidth != tabWidth-1 {
+					line = append(line, ' ')
+				}
+				line = append(line, ' ')
+			case r >= ' ' && r <= '~':
+				line = append(line, byte(r))
+			default:
+				line = append(line, '?')
+			}
+		}
+
+		for len(line) > columns {
+			lines = append(lines, string(line[:columns]))
+			line = line[columns:]
+		}
+		lines = append(lines, string(line))
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// drawGlyph draws the character c with its top left corner at x, y, each font pixel as a scale x scale square.
+func drawGlyph(img *image.Paletted, c byte, x, y, scale int) {
+	glyph := font5x7[c-' ']
+	for column, bits := range glyph {
+		for row := 0; row < glyphHeight; row++ {
+			if bits&(1<<row) == 0 {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(x+column*scale+dx, y+row*scale+dy, 1)
+				}
+			}
+		}
+	}
+}
+
+// font5x7 is a 5x7 pixel font of the printable ASCII characters, from ' ' to
+// '~'. Each glyph is stored as 5 columns from left to right, the least
+// significant bit of a column being its top pixel.
+var font5x7 = [95][glyphWidth]byte{
+	{0x00, 0x00, 0x00, 0x00, 0x00}, // ' '
+	{0x00, 0x00, 0x5f, 0x00, 0x00}, // '!'
+	{0x00, 0x07, 0x00, 0x07, 0x00}, // '"'
+	{0x14, 0x7f, 0x14, 0x7f, 0x14}, // '#'
+	{0x24, 0x2a, 0x7f, 0x2a, 0x12}, // '$'
+	{0x23, 0x13, 0x08, 0x64, 0x62}, // '%'
+	{0x36, 0x49, 0x55, 0x22, 0x50}, // '&'
+	{0x00, 0x05, 0x03, 0x00, 0x00}, // '\''
+	{0x00, 0x1c, 0x22, 0x41, 0x00}, // '('
+	{0x00, 0x41, 0x22, 0x1c, 0x00}, // ')'
+	{0x08, 0x2a, 0x1c, 0x2a, 0x08}, // '*'
+	{0x08, 0x08, 0x3e, 0x08, 0x08}, // '+'
+	{0x00, 0x50, 0x30, 0x00, 0x00}, // ','
+	{0x08, 0x08, 0x08, 0x08, 0x08}, // '-'
+	{0x00, 0x60, 0x60, 0x00, 0x00}, // '.'
+	{0x20, 0x10, 0x08, 0x04, 0x02}, // '/'
+	{0x3e, 0x51, 0x49, 0x45, 0x3e}, // '0'
+	{0x00, 0x42, 0x7f, 0x40, 0x00}, // '1'
+	{0x42, 0x61, 0x51, 0x49, 0x46}, // '2'
+	{0x21, 0x41, 0x45, 0x4b, 0x31}, // '3'
+	{0x18, 0x14, 0x12, 0x7f, 0x10}, // '4'
+	{0x27, 0x45, 0x45, 0x45, 0x39}, // '5'
+	{0x3c, 0x4a, 0x49, 0x49, 0x30}, // '6'
+	{0x01, 0x71, 0x09, 0x05, 0x03}, // '7'
+	{0x36, 0x49, 0x49, 0x49, 0x36}, // '8'
+	{0x06, 0x49, 0x49, 0x29, 0x1e}, // '9'
+	{0x00, 0x36, 0x36, 0x00, 0x00}, // ':'
+	{0x00, 0x56, 0x36, 0x00, 0x00}, // ';'
+	{0x08, 0x14, 0x22, 0x41, 0x00}, // '<'
+	{0x14, 0x14, 0x14, 0x14, 0x14}, // '='
+	{0x00, 0x41, 0x22, 0x14, 0x08}, // '>'
+	{0x02, 0x01, 0x51, 0x09, 0x06}, // '?'
+	{0x32, 0x49, 0x79, 0x41, 0x3e}, // '@'
+	{0x7e, 0x11, 0x11, 0x11, 0x7e}, // 'A'
+	{0x7f, 0x49, 0x49, 0x49, 0x36}, // 'B'
+	{0x3e, 0x41, 0x41, 0x41, 0x22}, // 'C'
+	{0x7f, 0x41, 0x41, 0x22, 0x1c}, // 'D'
+	{0x7f, 0x49, 0x49, 0x49, 0x41}, // 'E'
+	{0x7f, 0x09, 0x09, 0x09, 0x01}, // 'F'
+	{0x3e, 0x41, 0x49, 0x49, 0x7a}, // 'G'
+	{0x7f, 0x08, 0x08, 0x08, 0x7f}, // 'H'
+	{0x00, 0x41, 0x7f, 0x41, 0x00}, // 'I'
+	{0x20, 0x40, 0x41, 0x3f, 0x01}, // 'J'
+	{0x7f, 0x08, 0x14, 0x22, 0x41}, // 'K'
+	{0x7f, 0x40, 0x40, 0x40, 0x40}, // 'L'
+	{0x7f, 0x02, 0x0c, 0x02, 0x7f}, // 'M'
+	{0x7f, 0x04, 0x08, 0x10, 0x7f}, // 'N'
+	{0x3e, 0x41, 0x41, 0x41, 0x3e}, // 'O'
+	{0x7f, 0x09, 0x09, 0x09, 0x06}, // 'P'
+	{0x3e, 0x41, 0x51, 0x21, 0x5e}, // 'Q'
+	{0x7f, 0x09, 0x19, 0x29, 0x46}, // 'R'
+	{0x46, 0x49, 0x49, 0x49, 0x31}, // 'S'
+	{0x01, 0x01, 0x7f, 0x01, 0x01}, // 'T'
+	{0x3f, 0x40, 0x40, 0x40, 0x3f}, // 'U'
+	{0x1f, 0x20, 0x40, 0x20, 0x1f}, // 'V'
+	{0x3f, 0x40, 0x38, 0x40, 0x3f}, // 'W'
+	{0x63, 0x14, 0x08, 0x14, 0x63}, // 'X'
+	{0x07, 0x08, 0x70, 0x08, 0x07}, // 'Y'
+	{0x61, 0x51, 0x49, 0x45, 0x43}, // 'Z'
+	{0x00, 0x7f, 0x41, 0x41, 0x00}, // '['
+	{0x02, 0x04, 0x08, 0x10, 0x20}, // '\\'
+	{0x00, 0x41, 0x41, 0x7f, 0x00}, // ']'
+	{0x04, 0x02, 0x01, 0x02, 0x04}, // '^'
+	{0x40, 0x40, 0x40, 0x40, 0x40}, // '_'
+	{0x00, 0x01, 0x02, 0x04, 0x00}, // '`'
+	{0x20, 0x54, 0x54, 0x54, 0x78}, // 'a'
+	{0x7f, 0x48, 0x44, 0x44, 0x38}, // 'b'
+	{0x38, 0x44, 0x44, 0x44, 0x20}, // 'c'
+	{0x38, 0x44, 0x44, 0x48, 0x7f}, // 'd'
+	{0x38, 0x54, 0x54, 0x54, 0x18}, // 'e'
+	{0x08, 0x7e, 0x09, 0x01, 0x02}, // 'f'
+	{0x0c, 0x52, 0x52, 0x52, 0x3e}, // 'g'
+	{0x7f, 0x08, 0x04, 0x04, 0x78}, // 'h'
+	{0x00, 0x44, 0x7d, 0x40, 0x00}, // 'i'
+	{0x20, 0x40, 0x44, 0x3d, 0x00}, // 'j'
+	{0x7f, 0x10, 0x28, 0x44, 0x00}, // 'k'
+	{0x00, 0x41, 0x7f, 0x40, 0x00}, // 'l'
+	{0x7c, 0x04, 0x18, 0x04, 0x78}, // 'm'
+	{0x7c, 0x08, 0x04, 0x04, 0x78}, // 'n'
+	{0x38, 0x44, 0x44, 0x44, 0x38}, // 'o'
+	{0x7c, 0x14, 0x14, 0x14, 0x08}, // 'p'
+	{0x08, 0x14, 0x14, 0x18, 0x7c}, // 'q'
+	{0x7c, 0x08, 0x04, 0x04, 0x08}, // 'r'
+	{0x48, 0x54, 0x54, 0x54, 0x20}, // 's'
+	{0x04, 0x3f, 0x44, 0x40, 0x20}, // 't'
+	{0x3c, 0x40, 0x40, 0x20, 0x7c}, // 'u'
+	{0x1c, 0x20, 0x40, 0x20, 0x1c}, // 'v'
+	{0x3c, 0x40, 0x30, 0x40, 0x3c}, // 'w'
+	{0x44, 0x28, 0x10, 0x28, 0x44}, // 'x'
+	{0x0c, 0x50, 0x50, 0x50, 0x3c}, // 'y'
+	{0x44, 0x64, 0x54, 0x4c, 0x44}, // 'z'
+	{0x00, 0x08, 0x36, 0x41, 0x00}, // '{'
+	{0x00, 0x00, 0x7f, 0x00, 0x00}, // '|'
+	{0x00, 0x41, 0x36, 0x08, 0x00}, // '}'
+	{0x08, 0x04, 0x08, 0x10, 0x08}, // '~'
+}
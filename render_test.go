@@ -0,0 +1,65 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderText(t *testing.T) {
+	assert := assert.New(t)
+
+	data, err := RenderText("hello\nworld!", RenderOptions{Scale: 1})
+	assert.NoError(err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(err, "[TestRenderText] Expected a PNG image")
+	assert.Equal(2*4+6*cellWidth, img.Bounds().Dx(), "[TestRenderText] Expected image to be as wide as the longest line")
+	assert.Equal(2*4+2*cellHeight, img.Bounds().Dy(), "[TestRenderText] Expected image to be as high as two lines")
+
+	// The left column of 'h' is fully set, the space after it is not.
+	assert.Equal(renderPalette[1], img.At(4, 4+3), "[TestRenderText] Expected glyph to be drawn in the foreground colour")
+	assert.Equal(renderPalette[0], img.At(4+glyphWidth, 4+3), "[TestRenderText] Expected space between glyphs to be background")
+
+	_, err = RenderText("\n \n", RenderOptions{})
+	assert.Error(err, "[TestRenderText] Expected blank text to not be rendered")
+}
+
+func TestRenderTextLayout(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"abcd", "ef"}, layoutText("abcdef", 4), "[TestRenderTextLayout] Expected long lines to be wrapped")
+	assert.Equal([]string{"a   b", "?"}, layoutText("a\tb\r\n€\n\n", 80), "[TestRenderTextLayout] Expected tabs to be expanded and other characters replaced")
+
+	log := strings.Repeat("line\n", 300)
+	data, err := RenderText(log, RenderOptions{Scale: 1, MaxLines: 10})
+	assert.NoError(err)
+
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	assert.NoError(err)
+	assert.Equal(2*4+10*cellHeight, config.Height, "[TestRenderTextLayout] Expected only the last lines to be rendered")
+}
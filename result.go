@@ -0,0 +1,107 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDeliveryFailed is returned by the send methods when the notification could not be delivered to any of the devices.
+var ErrDeliveryFailed = errors.New("pushnotifier: notification was not delivered to any device")
+
+type (
+	// SendResult lists, per device, whether a notification was delivered.
+	SendResult struct {
+		// Delivered holds the IDs of the devices the notification was delivered to.
+		Delivered []string
+		// Failed holds the devices the notification could not be delivered to.
+		Failed []DeliveryFailure
+		// Image describes how the image of a notification sent with Image.Fit was fitted to the size limit.
+		Image *ImageFit
+	}
+
+	// DeliveryFailure describes a device a notification could not be delivered to.
+	DeliveryFailure struct {
+		DeviceID string
+		Reason   string
+	}
+)
+
+// Partial reports whether the notification was delivered to some, but not all, devices.
+func (r *SendResult) Partial() bool {
+	return len(r.Delivered) > 0 && len(r.Failed) > 0
+}
+
+// FailedIDs returns the IDs of the devices the notification could not be delivered to.
+func (r *SendResult) FailedIDs() []string {
+	ids := make([]string, 0, len(r.Failed))
+	for _, failure := range r.Failed {
+		ids = append(ids, failure.DeviceID)
+	}
+	return ids
+}
+
+// err returns an error wrapping ErrDeliveryFailed if no device received the notification.
+func (r *SendResult) err(caller string) error {
+	if len(r.Delivered) == 0 && len(r.Failed) > 0 {
+		return fmt.Errorf("[%v] %w: %v", caller, ErrDeliveryFailed, strings.Join(r.FailedIDs(), ", "))
+	}
+	return nil
+}
+
+// newSendResult builds a SendResult from the response of a notifications endpoint.
+//
+// The API answers with the IDs of the devices the notification was sent to in
+// `success`, and the IDs of the devices it could not be sent to in `error`.
+// If `success` is not a list of IDs, every requested device that is not listed
+// in `error` is considered delivered.
+func newSendResult(devices []string, sResp serverRespSuccess) *SendResult {
+	result := &SendResult{
+		Delivered: make([]string, 0),
+		Failed:    make([]DeliveryFailure, 0),
+	}
+
+	failed := make(map[string]bool, len(sResp.Error))
+	for _, id := range sResp.Error {
+		failed[id] = true
+		result.Failed = append(result.Failed, DeliveryFailure{DeviceID: id, Reason: "rejected by pushnotifier.de"})
+	}
+
+	if delivered, ok := sResp.Success.([]interface{}); ok {
+		for _, id := range delivered {
+			if id, ok := id.(string); ok {
+				result.Delivered = append(result.Delivered, id)
+			}
+		}
+		return result
+	}
+
+	for _, id := range devices {
+		if !failed[id] {
+			result.Delivered = append(result.Delivered, id)
+		}
+	}
+
+	return result
+}
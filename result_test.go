@@ -0,0 +1,61 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendResult(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	respBody := `{"success": ["abcd"], "error": ["efgh"]}`
+	handler.HandleFunc("/notifications/text", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, respBody)
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+
+	result, err := pn.SendText("hello world", []string{"abcd", "efgh"}, false)
+	assert.NoError(err, "[TestSendResult] Expected partial delivery to not return an error")
+	assert.Equal([]string{"abcd"}, result.Delivered)
+	assert.Equal([]string{"efgh"}, result.FailedIDs())
+	assert.True(result.Partial(), "[TestSendResult] Expected result to be a partial delivery")
+
+	respBody = `{"success": [], "error": ["abcd", "efgh"]}`
+
+	result, err = pn.SendText("hello world", []string{"abcd", "efgh"}, false)
+	assert.ErrorIs(err, ErrDeliveryFailed, "[TestSendResult] Expected failed delivery to all devices to return ErrDeliveryFailed")
+	assert.Equal([]string{"abcd", "efgh"}, result.FailedIDs())
+	assert.False(result.Partial(), "[TestSendResult] Expected result to not be a partial delivery")
+}
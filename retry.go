@@ -0,0 +1,181 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how Client retries requests that failed with a transient error.
+//
+// Requests are only retried when doing so cannot deliver a notification twice:
+// GET requests are retried on any retryable status code or network error, while
+// other requests, e.g. sending a notification, are only retried when the server
+// explicitly rejected them with 429 or 503, or when no connection could be established.
+// Set RetryNonIdempotent to retry those in all cases as well.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one. Values below 2 disable retries.
+	MaxAttempts int
+	// BaseBackoff is the wait before the first retry. It doubles with every further retry.
+	BaseBackoff time.Duration
+	// MaxBackoff caps the wait between two attempts. A Retry-After header asking for a longer wait ends retrying.
+	MaxBackoff time.Duration
+	// Jitter is the fraction, between 0 and 1, of each backoff that is randomized to spread out retries of concurrent clients.
+	Jitter float64
+	// RetryableStatusCodes lists the response status codes that are retried.
+	RetryableStatusCodes []int
+	// RetryNetworkErrors enables retrying requests that failed without a response, e.g. on a connection reset.
+	RetryNetworkErrors bool
+	// RetryNonIdempotent enables retrying non-GET requests in all cases, which may deliver a notification more than once.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy making up to 3 attempts, retrying
+// rate limiting, gateway and server unavailability errors, and network errors.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseBackoff: 500 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+		Jitter:      0.2,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryNetworkErrors: true,
+	}
+}
+
+// backoff returns how long to wait before the given retry, starting at 1.
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	wait := float64(p.BaseBackoff) * math.Pow(2, float64(retry-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		wait -= wait * math.Min(p.Jitter, 1) * rand.Float64()
+	}
+
+	return time.Duration(wait)
+}
+
+func (p *RetryPolicy) retryableStatus(statusCode int) bool {
+	for _, code := range p.RetryableStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// retryAfter decides whether a request should be attempted again after attempt
+// attempts, and how long to wait before doing so. resp is nil if the request failed with err.
+func (p *RetryPolicy) retryAfter(method string, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if p == nil || attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	idempotent := method == http.MethodGet || method == http.MethodHead || p.RetryNonIdempotent
+
+	if resp == nil {
+		if !p.RetryNetworkErrors || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return 0, false
+		}
+		if !idempotent && !isDialError(err) {
+			return 0, false
+		}
+		return p.backoff(attempt), true
+	}
+
+	if !p.retryableStatus(resp.StatusCode) {
+		return 0, false
+	}
+
+	// The server did not handle a rejected request, so it is safe to send it again.
+	rejected := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+	if !idempotent && !rejected {
+		return 0, false
+	}
+
+	if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok && rejected {
+		if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+			return 0, false
+		}
+		return wait, true
+	}
+
+	return p.backoff(attempt), true
+}
+
+// isDialError reports whether err occurred while connecting, i.e. before any of the request was sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// parseRetryAfter parses the value of a Retry-After header, given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	wait := date.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+// sleepContext waits for d, returning early with the context's error if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var calls int32
+	handler.HandleFunc("/notifications/text", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"success": ["abcd"], "error": []}`)
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+	policy := DefaultRetryPolicy()
+	policy.BaseBackoff = time.Hour
+	pn.RetryPolicy = &policy
+
+	result, err := pn.SendText("hello world", []string{"abcd"}, false)
+	assert.NoError(err, "[TestRetryHonoursRetryAfter] Expected request to succeed after retrying")
+	assert.Equal([]string{"abcd"}, result.Delivered)
+	assert.Equal(int32(3), atomic.LoadInt32(&calls), "[TestRetryHonoursRetryAfter] Expected 3 attempts")
+}
+
+func TestRetrySkipsUnsafeRequests(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	var sendCalls, deviceCalls int32
+	handler.HandleFunc("/notifications/text", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&sendCalls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	handler.HandleFunc("/devices", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&deviceCalls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	pn := NewClient(nil, "dev.myapp.pn", "aabbccdd112233", "ZZXX11ff")
+	pn.BaseURL, _ = url.Parse(server.URL)
+	policy := DefaultRetryPolicy()
+	policy.BaseBackoff = time.Millisecond
+	pn.RetryPolicy = &policy
+
+	_, err := pn.SendText("hello world", []string{"abcd"}, false)
+	assert.ErrorIs(err, ErrServer)
+	assert.Equal(int32(1), atomic.LoadInt32(&sendCalls), "[TestRetrySkipsUnsafeRequests] Expected a notification to not be resent after a 502")
+
+	_, err = pn.GetDevices()
+	assert.ErrorIs(err, ErrServer)
+	assert.Equal(int32(policy.MaxAttempts), atomic.LoadInt32(&deviceCalls), "[TestRetrySkipsUnsafeRequests] Expected GET requests to be retried after a 502")
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2022, 9, 28, 12, 0, 0, 0, time.UTC)
+
+	wait, ok := parseRetryAfter("120", now)
+	assert.True(ok)
+	assert.Equal(2*time.Minute, wait)
+
+	wait, ok = parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now)
+	assert.True(ok)
+	assert.Equal(30*time.Second, wait)
+
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(ok)
+}
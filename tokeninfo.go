@@ -0,0 +1,78 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import "time"
+
+// TokenInfo describes the App Token of a Client and the user it belongs to.
+type TokenInfo struct {
+	// UserName is the name of the user, known once the client logged in or refreshed the App Token.
+	UserName string
+	// Avatar is the URL of the user's avatar, known once the client logged in or refreshed the App Token.
+	Avatar string
+	// HasToken reports whether the client has an App Token at all.
+	HasToken bool
+	// ExpiresAt is the time the App Token expires at. It is the zero time if
+	// the expiry is unknown, in which case the token is never refreshed.
+	ExpiresAt time.Time
+}
+
+// TokenInfo returns information about the client's App Token. It does not make any request.
+func (c *Client) TokenInfo() TokenInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	info := TokenInfo{
+		UserName: c.UserName,
+		Avatar:   c.avatar,
+		HasToken: c.AppToken != "",
+	}
+	if c.AppTokenExpiry > 0 {
+		info.ExpiresAt = time.Unix(c.AppTokenExpiry, 0)
+	}
+
+	return info
+}
+
+// Remaining returns how long the App Token is valid for at now. It is negative
+// once the token expired, and zero if the expiry is unknown.
+func (t TokenInfo) Remaining(now time.Time) time.Duration {
+	if t.ExpiresAt.IsZero() {
+		return 0
+	}
+	return t.ExpiresAt.Sub(now)
+}
+
+// RefreshAt returns the time from which the App Token is refreshed by the next
+// request, i.e. RefreshWindow before it expires. It is the zero time if the expiry is unknown.
+func (t TokenInfo) RefreshAt() time.Time {
+	if t.ExpiresAt.IsZero() {
+		return time.Time{}
+	}
+	return t.ExpiresAt.Add(-RefreshWindow)
+}
+
+// InRefreshWindow reports whether, at now, the App Token is due to be refreshed
+// by the next request, i.e. expires within RefreshWindow or already expired.
+func (t TokenInfo) InRefreshWindow(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && t.Remaining(now) < RefreshWindow
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2022 Maverick Kaung <mavjs01@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package pushnotifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := http.NewServeMux()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	expiresAt := time.Now().Add(30 * 24 * time.Hour).Unix()
+	handler.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"username": "aUser", "avatar": "https://example.com/avatar/0", "app_token": "ZZXX11ff", "expires_at": %d}`, expiresAt)
+	})
+
+	pn, err := New("dev.myapp.pn", "aabbccdd112233", WithBaseURL(server.URL))
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.False(pn.TokenInfo().HasToken, "[TestTokenInfo] Expected a new client to have no App Token")
+
+	assert.NoError(pn.Login("aUser", "aUserPassword"))
+
+	now := time.Now()
+	info := pn.TokenInfo()
+	assert.True(info.HasToken)
+	assert.Equal("aUser", info.UserName)
+	assert.Equal("https://example.com/avatar/0", info.Avatar)
+	assert.Equal(expiresAt, info.ExpiresAt.Unix())
+	assert.False(info.InRefreshWindow(now), "[TestTokenInfo] Expected a fresh App Token to be outside the refresh window")
+	assert.Equal(info.ExpiresAt.Add(-RefreshWindow), info.RefreshAt())
+	assert.True(info.InRefreshWindow(info.ExpiresAt.Add(-time.Minute)), "[TestTokenInfo] Expected an App Token expiring in a minute to be inside the refresh window")
+
+	pn.SetToken("ZZXX11ff", -1)
+	info = pn.TokenInfo()
+	assert.True(info.ExpiresAt.IsZero(), "[TestTokenInfo] Expected an unknown expiry to be the zero time")
+	assert.False(info.InRefreshWindow(now), "[TestTokenInfo] Expected a token with unknown expiry to never be refreshed")
+}